@@ -38,7 +38,7 @@ func main() {
 		*dir = filepath.Join(*addr, *dir)
 	}
 
-	cli := obsidian.NewVault(context.Background(), *dir, conn, nil)
+	cli := obsidian.NewVault(context.Background(), *dir, conn, nil, false)
 	if err := cli.Refresh(obsidian.Scheduled); err != nil {
 		panic(err)
 	}
@@ -47,7 +47,7 @@ func main() {
 	for {
 		tasks := cli.GetTasks()
 		for _, t := range tasks {
-			fmt.Println(t.Hash(), t.String())
+			fmt.Println(t.ID(), t.String())
 		}
 		<-time.After(30 * time.Second)
 	}
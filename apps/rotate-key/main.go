@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/config"
+	"github.com/RacoonMediaServer/rms-notes/internal/db"
+	"github.com/RacoonMediaServer/rms-notes/internal/secret"
+	"go-micro.dev/v4/logger"
+)
+
+// rotate-key re-encrypts stored passwords with a new key. If the service keeps the key in the key file, the current key
+// is read from the file and the file is replaced with the new key. Otherwise set the new key to the service configuration
+// after rotation
+func main() {
+	configFile := flag.String("config", "/etc/rms/rms-notes.json", "Service configuration file")
+	oldKey := flag.String("old-key", "", "Current encryption key (read from the key file if empty)")
+	newKey := flag.String("new-key", "", "New encryption key (generated if empty and the key file is used)")
+	flag.Parse()
+
+	if err := config.Load(*configFile); err != nil {
+		logger.Fatalf("Load configuration failed: %s", err)
+	}
+	security := config.Config().Security
+	keyFile := ""
+	if security.EncryptionKey == "" {
+		keyFile = security.KeyFile
+	}
+
+	var err error
+	if *oldKey == "" && keyFile != "" {
+		if *oldKey, err = secret.LoadKey(keyFile); err != nil {
+			logger.Fatalf("Load current key failed: %s", err)
+		}
+	}
+	if *newKey == "" && keyFile != "" {
+		if *newKey, err = secret.GenerateKey(); err != nil {
+			logger.Fatalf("Generate new key failed: %s", err)
+		}
+	}
+
+	oldCipher, err := secret.NewCipher(*oldKey)
+	if err != nil {
+		logger.Fatalf("Invalid old key: %s", err)
+	}
+	newCipher, err := secret.NewCipher(*newKey)
+	if err != nil {
+		logger.Fatalf("Invalid new key: %s", err)
+	}
+
+	database, err := db.Connect(config.Config().Database, nil)
+	if err != nil {
+		logger.Fatalf("Connect to database failed: %s", err)
+	}
+
+	// the new key is saved before the rotation, so it is not lost if the file cannot be written
+	pendingFile := keyFile + ".new"
+	if keyFile != "" {
+		_ = os.Remove(pendingFile)
+		if err = secret.SaveKey(pendingFile, *newKey); err != nil {
+			logger.Fatalf("Save new key failed: %s", err)
+		}
+	}
+
+	if err = database.RotateKey(oldCipher, newCipher); err != nil {
+		if keyFile != "" {
+			_ = os.Remove(pendingFile)
+		}
+		logger.Fatalf("Rotate key failed: %s", err)
+	}
+
+	if keyFile == "" {
+		logger.Info("Passwords are re-encrypted, set the new key to the service configuration")
+		return
+	}
+	if err = os.Rename(pendingFile, keyFile); err != nil {
+		logger.Fatalf("Replace key file failed, move %s to %s manually: %s", pendingFile, keyFile, err)
+	}
+	logger.Infof("Passwords are re-encrypted, the new key is saved to %s", keyFile)
+}
@@ -2,11 +2,61 @@ package config
 
 import "github.com/RacoonMediaServer/rms-packages/pkg/configuration"
 
+// Escalation configures reminders about ignored overdue tasks
+type Escalation struct {
+	// UrgentAfter is a number of reminders after which the task is reminded as urgent
+	UrgentAfter int
+	// StaleAfter is a number of reminders after which the task is moved to the weekly digest of stale tasks
+	StaleAfter int
+	// DigestWeekday is a day of week (0 - Sunday) when the stale tasks digest is sent
+	DigestWeekday int
+}
+
+// Review configures the weekly review note
+type Review struct {
+	// Enabled turns on writing of the weekly review note
+	Enabled bool
+	// Weekday is a day of week (0 - Sunday) when the review note is written
+	Weekday int
+	// Folder is a vault folder of the review notes
+	Folder string
+	// FileName is a template of the note title, e.g. "Review {{.Year}}-W{{.Week}}". Available fields: Date, Year, Week
+	FileName string
+	// SnoozedAfter is a number of snoozes after which the task is listed in the review
+	SnoozedAfter int
+}
+
+// Security configures protection of the stored secrets
+type Security struct {
+	// EncryptionKey is a base64 encoded AES key (16, 24 or 32 bytes) which encrypts stored passwords
+	EncryptionKey string
+	// KeyFile is a file of the key which is used if EncryptionKey is not set. The key is generated on the first start, the
+	// file must be kept on a persistent volume: the service does not start without it once the secrets are encrypted
+	KeyFile string
+}
+
+// Git configures the vaults kept in git repositories
+type Git struct {
+	// Directory is a folder of the local clones
+	Directory string
+}
+
 // Configuration represents entire service configuration
 type Configuration struct {
-	Database configuration.Database
-	Debug    configuration.Debug
-	Async    bool
+	Database   configuration.Database
+	Debug      configuration.Debug
+	Async      bool
+	Escalation Escalation
+	Review     Review
+	Security   Security
+	Git        Git
+
+	// CompleteSubtasks is a default of the user setting which enables completion of the open subtasks together with the
+	// parent task, otherwise the user is warned about them
+	CompleteSubtasks bool
+
+	// Statuses maps custom task status symbols to status types (todo, in_progress, done, cancelled, non_task)
+	Statuses map[string]string
 }
 
 var config Configuration
@@ -2,6 +2,7 @@ package db
 
 import (
 	"github.com/RacoonMediaServer/rms-notes/internal/model"
+	"github.com/RacoonMediaServer/rms-notes/internal/secret"
 	"github.com/RacoonMediaServer/rms-packages/pkg/configuration"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -9,16 +10,31 @@ import (
 
 // Database represents all database methods
 type Database struct {
-	conn *gorm.DB
+	conn   *gorm.DB
+	cipher *secret.Cipher
 }
 
-func Connect(dbConfig configuration.Database) (*Database, error) {
+// Connect opens the database. Passwords are encrypted by the cipher, nil cipher keeps them in plaintext
+func Connect(dbConfig configuration.Database, cipher *secret.Cipher) (*Database, error) {
 	db, err := gorm.Open(postgres.Open(dbConfig.GetConnectionString()))
 	if err != nil {
 		return nil, err
 	}
-	if err = db.AutoMigrate(&notesSettings{}, &model.NotesUser{}); err != nil {
+	if err = db.AutoMigrate(&notesSettings{}, &model.NotesUser{}, &model.NotificationSettings{}, &model.SentReminder{}, &model.ReminderStats{}, &model.SnoozeStats{}, &model.UserSettings{}, &model.NotesVault{}, &model.VaultMember{}); err != nil {
 		return nil, err
 	}
-	return &Database{conn: db}, nil
+	d := &Database{conn: db}
+	if err = d.SetCipher(cipher); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// SetCipher changes the cipher of the passwords and encrypts the passwords which are still stored in plaintext
+func (d *Database) SetCipher(cipher *secret.Cipher) error {
+	d.cipher = cipher
+	if cipher == nil {
+		return nil
+	}
+	return d.encryptPasswords()
 }
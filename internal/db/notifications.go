@@ -0,0 +1,82 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/model"
+	"gorm.io/gorm"
+)
+
+func (d *Database) LoadNotificationSettings(user int32) (*model.NotificationSettings, error) {
+	settings := model.NotificationSettings{TelegramUser: user}
+	err := d.conn.First(&settings, user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (d *Database) SaveNotificationSettings(settings *model.NotificationSettings) error {
+	return d.conn.Save(settings).Error
+}
+
+func (d *Database) IsReminderSent(r *model.SentReminder) (bool, error) {
+	var count int64
+	err := d.conn.Model(&model.SentReminder{}).
+		Where("telegram_user = ? AND task_id = ? AND due_date = ? AND days_before = ?", r.TelegramUser, r.TaskID, r.DueDate, r.DaysBefore).
+		Count(&count).Error
+	return count != 0, err
+}
+
+func (d *Database) AddSentReminder(r *model.SentReminder) error {
+	return d.conn.Save(r).Error
+}
+
+func (d *Database) RemoveSentReminders(before time.Time) error {
+	return d.conn.Where("due_date < ?", before).Delete(&model.SentReminder{}).Error
+}
+
+func (d *Database) LoadReminderStats(user int32, taskId string, dueDate time.Time) (*model.ReminderStats, error) {
+	stats := model.ReminderStats{TelegramUser: user, TaskID: taskId, DueDate: dueDate}
+	err := d.conn.Where("telegram_user = ? AND task_id = ? AND due_date = ?", user, taskId, dueDate).First(&stats).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (d *Database) SaveReminderStats(stats *model.ReminderStats) error {
+	return d.conn.Save(stats).Error
+}
+
+func (d *Database) RemoveReminderStats(before time.Time) error {
+	return d.conn.Where("last_reminded_at < ?", before).Delete(&model.ReminderStats{}).Error
+}
+
+func (d *Database) IncSnoozeCount(user int32, taskId string, now time.Time) error {
+	stats := model.SnoozeStats{TelegramUser: user, TaskID: taskId}
+	err := d.conn.Where("telegram_user = ? AND task_id = ?", user, taskId).First(&stats).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	stats.Count++
+	stats.LastSnoozedAt = now
+	return d.conn.Save(&stats).Error
+}
+
+func (d *Database) LoadSnoozeStats(user int32) (map[string]int, error) {
+	var records []model.SnoozeStats
+	if err := d.conn.Where("telegram_user = ?", user).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[string]int, len(records))
+	for _, r := range records {
+		result[r.TaskID] = r.Count
+	}
+	return result, nil
+}
+
+func (d *Database) RemoveSnoozeStats(before time.Time) error {
+	return d.conn.Where("last_snoozed_at < ?", before).Delete(&model.SnoozeStats{}).Error
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/model"
+	"github.com/RacoonMediaServer/rms-notes/internal/secret"
+	"gorm.io/gorm"
+)
+
+// encryptPasswords encrypts passwords which are still stored in plaintext
+func (d *Database) encryptPasswords() error {
+	return d.conn.Transaction(func(tx *gorm.DB) error {
+		return reencrypt(tx, func(value string) (string, error) {
+			if value == "" || secret.IsEncrypted(value) {
+				return value, nil
+			}
+			return d.cipher.Encrypt(value)
+		})
+	})
+}
+
+// HasEncryptedSecrets returns true if any stored password, token or private key is encrypted
+func (d *Database) HasEncryptedSecrets() (bool, error) {
+	var users []*model.NotesUser
+	if err := d.conn.Find(&users).Error; err != nil {
+		return false, err
+	}
+	for _, u := range users {
+		if secret.IsEncrypted(u.Password) || secret.IsEncrypted(u.Token) || secret.IsEncrypted(u.PrivateKey) {
+			return true, nil
+		}
+	}
+
+	var vaults []*model.NotesVault
+	if err := d.conn.Find(&vaults).Error; err != nil {
+		return false, err
+	}
+	for _, v := range vaults {
+		if secret.IsEncrypted(v.Password) || secret.IsEncrypted(v.Token) || secret.IsEncrypted(v.PrivateKey) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RotateKey re-encrypts all stored passwords from the old key to the new one. Plaintext passwords are encrypted too
+func (d *Database) RotateKey(oldCipher, newCipher *secret.Cipher) error {
+	return d.conn.Transaction(func(tx *gorm.DB) error {
+		return reencrypt(tx, func(value string) (string, error) {
+			plain, err := oldCipher.Decrypt(value)
+			if err != nil {
+				return "", err
+			}
+			return newCipher.Encrypt(plain)
+		})
+	})
+}
+
+func reencrypt(tx *gorm.DB, convert func(value string) (string, error)) error {
+	var users []*model.NotesUser
+	if err := tx.Find(&users).Error; err != nil {
+		return err
+	}
+	for _, u := range users {
+		password, err := convert(u.Password)
+		if err != nil {
+			return fmt.Errorf("convert password of user %d failed: %w", u.TelegramUser, err)
+		}
+		token, err := convert(u.Token)
+		if err != nil {
+			return fmt.Errorf("convert token of user %d failed: %w", u.TelegramUser, err)
+		}
+		privateKey, err := convert(u.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("convert private key of user %d failed: %w", u.TelegramUser, err)
+		}
+		if password == u.Password && token == u.Token && privateKey == u.PrivateKey {
+			continue
+		}
+		updates := map[string]interface{}{"password": password, "token": token, "private_key": privateKey}
+		if err = tx.Model(u).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	var vaults []*model.NotesVault
+	if err := tx.Find(&vaults).Error; err != nil {
+		return err
+	}
+	for _, v := range vaults {
+		password, err := convert(v.Password)
+		if err != nil {
+			return fmt.Errorf("convert password of vault %d failed: %w", v.ID, err)
+		}
+		token, err := convert(v.Token)
+		if err != nil {
+			return fmt.Errorf("convert token of vault %d failed: %w", v.ID, err)
+		}
+		privateKey, err := convert(v.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("convert private key of vault %d failed: %w", v.ID, err)
+		}
+		if password == v.Password && token == v.Token && privateKey == v.PrivateKey {
+			continue
+		}
+		updates := map[string]interface{}{"password": password, "token": token, "private_key": privateKey}
+		if err = tx.Model(v).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
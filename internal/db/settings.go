@@ -1,27 +1,75 @@
 package db
 
 import (
+	"errors"
+
 	"github.com/RacoonMediaServer/rms-notes/internal/config"
+	"github.com/RacoonMediaServer/rms-notes/internal/model"
 	rms_notes "github.com/RacoonMediaServer/rms-packages/pkg/service/rms-notes"
+	"gorm.io/gorm"
 )
 
+// notesSettings keeps the global settings. The fields are copied from rms_notes.NotesSettings because the protobuf
+// message must not be copied by value
 type notesSettings struct {
-	ID       uint                    `gorm:"primaryKey"`
-	Settings rms_notes.NotesSettings `gorm:"embedded"`
+	ID               uint `gorm:"primaryKey"`
+	Directory        string
+	NotesDirectory   string
+	TasksFile        string
+	NotificationTime uint32
 }
 
 func (d *Database) LoadSettings() (*rms_notes.NotesSettings, error) {
 	var record notesSettings
 	defaultSettings := notesSettings{
-		ID:       1,
-		Settings: config.DefaultSettings,
+		ID:               1,
+		Directory:        config.DefaultSettings.Directory,
+		NotesDirectory:   config.DefaultSettings.NotesDirectory,
+		TasksFile:        config.DefaultSettings.TasksFile,
+		NotificationTime: config.DefaultSettings.NotificationTime,
 	}
 	if err := d.conn.Where(notesSettings{ID: 1}).Attrs(defaultSettings).FirstOrCreate(&record).Error; err != nil {
 		return nil, err
 	}
-	return &record.Settings, nil
+	return &rms_notes.NotesSettings{
+		Directory:        record.Directory,
+		NotesDirectory:   record.NotesDirectory,
+		TasksFile:        record.TasksFile,
+		NotificationTime: record.NotificationTime,
+	}, nil
 }
 
 func (d *Database) SaveSettings(val *rms_notes.NotesSettings) error {
-	return d.conn.Save(&notesSettings{ID: 1, Settings: *val}).Error
+	return d.conn.Save(&notesSettings{
+		ID:               1,
+		Directory:        val.Directory,
+		NotesDirectory:   val.NotesDirectory,
+		TasksFile:        val.TasksFile,
+		NotificationTime: val.NotificationTime,
+	}).Error
+}
+
+func (d *Database) LoadUserSettings() (map[int32]*model.UserSettings, error) {
+	var records []*model.UserSettings
+	if err := d.conn.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	result := map[int32]*model.UserSettings{}
+	for _, r := range records {
+		result[r.TelegramUser] = r
+	}
+	return result, nil
+}
+
+func (d *Database) GetUserSettings(user int32) (*model.UserSettings, error) {
+	settings := model.UserSettings{TelegramUser: user}
+	err := d.conn.First(&settings, user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (d *Database) SaveUserSettings(settings *model.UserSettings) error {
+	return d.conn.Save(settings).Error
 }
@@ -1,6 +1,12 @@
 package db
 
-import "github.com/RacoonMediaServer/rms-notes/internal/model"
+import (
+	"fmt"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/model"
+	"go-micro.dev/v4/logger"
+	"gorm.io/gorm"
+)
 
 func (d *Database) LoadUsers() (map[int32]*model.NotesUser, error) {
 	var users []*model.NotesUser
@@ -9,15 +15,66 @@ func (d *Database) LoadUsers() (map[int32]*model.NotesUser, error) {
 	}
 	result := map[int32]*model.NotesUser{}
 	for _, u := range users {
+		// the user is loaded with the locked credentials if they cannot be decrypted, so the other users keep working
+		if err := d.decryptUser(u); err != nil {
+			logger.Errorf("%s, the vaults of the user are disabled until the next login", err)
+			u.Password, u.Token, u.PrivateKey = "", "", ""
+			u.Locked = true
+		}
 		result[u.TelegramUser] = u
 	}
 	return result, nil
 }
 
+func (d *Database) decryptUser(u *model.NotesUser) error {
+	password, err := d.cipher.Decrypt(u.Password)
+	if err != nil {
+		return fmt.Errorf("decrypt password of user %d failed: %w", u.TelegramUser, err)
+	}
+	u.Password = password
+	if u.Token, err = d.cipher.Decrypt(u.Token); err != nil {
+		return fmt.Errorf("decrypt token of user %d failed: %w", u.TelegramUser, err)
+	}
+	if u.PrivateKey, err = d.cipher.Decrypt(u.PrivateKey); err != nil {
+		return fmt.Errorf("decrypt private key of user %d failed: %w", u.TelegramUser, err)
+	}
+	return nil
+}
+
 func (d *Database) AddUser(camera *model.NotesUser) error {
-	return d.conn.Create(camera).Error
+	record := *camera
+	password, err := d.cipher.Encrypt(camera.Password)
+	if err != nil {
+		return fmt.Errorf("encrypt password failed: %w", err)
+	}
+	record.Password = password
+	if record.Token, err = d.cipher.Encrypt(camera.Token); err != nil {
+		return fmt.Errorf("encrypt token failed: %w", err)
+	}
+	if record.PrivateKey, err = d.cipher.Encrypt(camera.PrivateKey); err != nil {
+		return fmt.Errorf("encrypt private key failed: %w", err)
+	}
+	return d.conn.Save(&record).Error
 }
 
+// RemoveUser removes the user with the stored credentials, the vaults of the user and the memberships in shared vaults
 func (d *Database) RemoveUser(id int32) error {
-	return d.conn.Model(&model.NotesUser{}).Unscoped().Delete(&model.NotesUser{}, id).Error
+	return d.conn.Transaction(func(tx *gorm.DB) error {
+		var vaults []uint
+		if err := tx.Model(&model.NotesVault{}).Where("telegram_user = ?", id).Pluck("id", &vaults).Error; err != nil {
+			return err
+		}
+		if len(vaults) != 0 {
+			if err := tx.Delete(&model.VaultMember{}, "vault_id IN ?", vaults).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(&model.VaultMember{}, "telegram_user = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&model.NotesVault{}, "telegram_user = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.NotesUser{}).Unscoped().Delete(&model.NotesUser{}, id).Error
+	})
 }
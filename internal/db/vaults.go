@@ -0,0 +1,81 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/model"
+	"go-micro.dev/v4/logger"
+)
+
+func (d *Database) LoadVaults() ([]*model.NotesVault, error) {
+	var vaults []*model.NotesVault
+	if err := d.conn.Order("id").Find(&vaults).Error; err != nil {
+		return nil, err
+	}
+	for _, v := range vaults {
+		if err := d.decryptVault(v); err != nil {
+			logger.Errorf("%s, the vault is disabled until it is added again", err)
+			v.Password, v.Token, v.PrivateKey = "", "", ""
+			v.Locked = true
+		}
+	}
+	return vaults, nil
+}
+
+func (d *Database) decryptVault(v *model.NotesVault) error {
+	password, err := d.cipher.Decrypt(v.Password)
+	if err != nil {
+		return fmt.Errorf("decrypt password of vault %d failed: %w", v.ID, err)
+	}
+	v.Password = password
+	if v.Token, err = d.cipher.Decrypt(v.Token); err != nil {
+		return fmt.Errorf("decrypt token of vault %d failed: %w", v.ID, err)
+	}
+	if v.PrivateKey, err = d.cipher.Decrypt(v.PrivateKey); err != nil {
+		return fmt.Errorf("decrypt private key of vault %d failed: %w", v.ID, err)
+	}
+	return nil
+}
+
+func (d *Database) AddVault(vault *model.NotesVault) error {
+	record := *vault
+	password, err := d.cipher.Encrypt(vault.Password)
+	if err != nil {
+		return fmt.Errorf("encrypt password failed: %w", err)
+	}
+	record.Password = password
+	if record.Token, err = d.cipher.Encrypt(vault.Token); err != nil {
+		return fmt.Errorf("encrypt token failed: %w", err)
+	}
+	if record.PrivateKey, err = d.cipher.Encrypt(vault.PrivateKey); err != nil {
+		return fmt.Errorf("encrypt private key failed: %w", err)
+	}
+	if err = d.conn.Create(&record).Error; err != nil {
+		return err
+	}
+	vault.ID = record.ID
+	return nil
+}
+
+func (d *Database) RemoveVault(id uint) error {
+	if err := d.conn.Delete(&model.VaultMember{}, "vault_id = ?", id).Error; err != nil {
+		return err
+	}
+	return d.conn.Delete(&model.NotesVault{}, id).Error
+}
+
+func (d *Database) LoadVaultMembers() ([]*model.VaultMember, error) {
+	var members []*model.VaultMember
+	if err := d.conn.Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (d *Database) SaveVaultMember(member *model.VaultMember) error {
+	return d.conn.Save(member).Error
+}
+
+func (d *Database) RemoveVaultMember(vaultId uint, user int32) error {
+	return d.conn.Delete(&model.VaultMember{}, "vault_id = ? AND telegram_user = ?", vaultId, user).Error
+}
@@ -0,0 +1,55 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/vault"
+)
+
+const checkTimeout = 60 * time.Second
+
+// Check verifies the access to the remote and the vault directory by the sparse clone without the file contents
+func Check(config Config, directory string) error {
+	if err := ValidateURL(config.URL); err != nil {
+		return err
+	}
+	if config.Branch == "" {
+		config.Branch = defaultBranch
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	tmp, err := os.MkdirTemp("", "rms-notes-check-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	_, err = run(ctx, config, tmp, "clone", "--quiet", "--depth", "1", "--no-checkout", "--filter=blob:none",
+		"--branch", config.Branch, "--", config.URL, "repo")
+	if err != nil {
+		if isAuthError(err) {
+			return vault.ErrInvalidCredentials
+		}
+		return fmt.Errorf("%w: %s", vault.ErrUnreachable, err)
+	}
+
+	directory = strings.Trim(filepath.ToSlash(filepath.Clean("/"+directory)), "/")
+	if directory == "" {
+		return nil
+	}
+	out, err := run(ctx, config, filepath.Join(tmp, "repo"), "ls-tree", "-d", "HEAD", "--", directory)
+	if err != nil {
+		return fmt.Errorf("%w: %s", vault.ErrUnreachable, err)
+	}
+	if out == "" {
+		return fmt.Errorf("%w: %s", vault.ErrDirectoryNotFound, directory)
+	}
+	return nil
+}
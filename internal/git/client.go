@@ -0,0 +1,392 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/vault"
+	"go-micro.dev/v4/logger"
+)
+
+const (
+	defaultBranch = "main"
+	authorName    = "rms-notes"
+	authorEmail   = "rms-notes@localhost"
+)
+
+// Config describes the remote repository of the vault and its local clone
+type Config struct {
+	// URL is a remote repository, e.g. https://github.com/user/vault.git or git@host:user/vault.git
+	URL string
+	// Branch is a synchronized branch, "main" is used if it is empty
+	Branch string
+	// User and Password are used for HTTPS remotes
+	User     string
+	Password string
+	// PrivateKey is a PEM encoded key without a passphrase for SSH remotes, the keys of the service are never used
+	PrivateKey string
+	// HostKey is a pinned public key of the SSH server in the authorized_keys format
+	HostKey string
+	// Directory is a path to the local clone
+	Directory string
+}
+
+// ErrUnsupportedURL is returned for the remotes other than HTTPS and SSH ones
+var ErrUnsupportedURL = errors.New("only https and ssh remotes are supported")
+
+// allowedProtocols restricts the transports of git, so the remote cannot point to the files of the service host
+var allowedProtocols = "https:ssh"
+
+// ValidateURL checks that the remote is an HTTPS or SSH URL, including the scp-like form [user@]host:path
+func ValidateURL(remote string) error {
+	if strings.HasPrefix(remote, "-") {
+		return ErrUnsupportedURL
+	}
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "ssh") || u.Host == "" {
+			return ErrUnsupportedURL
+		}
+		return nil
+	}
+	colon := strings.Index(remote, ":")
+	if colon <= 0 || strings.Contains(remote[:colon], "/") || strings.Contains(remote, "::") {
+		return ErrUnsupportedURL
+	}
+	return nil
+}
+
+type Client struct {
+	config Config
+	l      logger.Logger
+
+	mu     sync.Mutex
+	cloned bool
+}
+
+func NewClient(config Config) vault.Accessor {
+	if config.Branch == "" {
+		config.Branch = defaultBranch
+	}
+	return &Client{
+		config: config,
+		l:      logger.Fields(map[string]interface{}{"from": "git"}),
+	}
+}
+
+// run executes the git command in the local clone
+func (c *Client) run(ctx context.Context, args ...string) (string, error) {
+	return run(ctx, c.config, c.config.Directory, args...)
+}
+
+func run(ctx context.Context, config Config, dir string, args ...string) (string, error) {
+	options := [][2]string{{"user.name", authorName}, {"user.email", authorEmail}}
+	if config.User != "" || config.Password != "" {
+		// the credentials are passed with every command, so they are not stored in the clone. The environment is used
+		// instead of the arguments, so they are not visible in the process list
+		auth := base64.StdEncoding.EncodeToString([]byte(config.User + ":" + config.Password))
+		options = append(options, [2]string{"http.extraHeader", "Authorization: Basic " + auth})
+	}
+
+	sshCommand, cleanup, err := sshCommandOf(config)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ALLOW_PROTOCOL="+allowedProtocols,
+		"GIT_SSH_COMMAND="+sshCommand, "GIT_CONFIG_COUNT="+strconv.Itoa(len(options)))
+	for i, o := range options {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", i, o[0]), fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i, o[1]))
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.String(), &Error{Args: args, Err: err, Stderr: strings.TrimSpace(stderr.String())}
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// sshCommandOf returns the ssh command which authenticates with the key of the vault only. The configuration, the agent
+// and the keys of the service user are ignored. The key and the pinned host key are stored in the temporary files
+// removed by cleanup
+func sshCommandOf(config Config) (command string, cleanup func(), err error) {
+	var files []string
+	cleanup = func() {
+		for _, f := range files {
+			_ = os.Remove(f)
+		}
+	}
+	writeTemp := func(pattern, content string) (string, error) {
+		f, err := os.CreateTemp("", pattern)
+		if err != nil {
+			return "", err
+		}
+		files = append(files, f.Name())
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		_, err = f.WriteString(content)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return f.Name(), err
+	}
+
+	args := []string{"ssh", "-F", "/dev/null", "-o", "BatchMode=yes", "-o", "IdentitiesOnly=yes", "-o", "IdentityAgent=none"}
+	if config.PrivateKey != "" {
+		// CreateTemp makes the file readable by the owner only, as ssh requires
+		keyFile, err := writeTemp("rms-notes-key-", config.PrivateKey)
+		if err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		args = append(args, "-i", keyFile)
+	} else {
+		args = append(args, "-o", "IdentityFile=none")
+	}
+	if config.HostKey != "" {
+		knownHosts, err := writeTemp("rms-notes-known-hosts-", "* "+config.HostKey)
+		if err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		args = append(args, "-o", "StrictHostKeyChecking=yes", "-o", "UserKnownHostsFile="+knownHosts)
+	}
+
+	for i := range args {
+		args[i] = shellQuote(args[i])
+	}
+	return strings.Join(args, " "), cleanup, nil
+}
+
+// shellQuote quotes the argument of the command interpreted by the shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ensureClonedUnsafe clones the remote repository on the first access. Must be called under the lock
+func (c *Client) ensureClonedUnsafe() error {
+	if c.cloned {
+		return nil
+	}
+	if err := ValidateURL(c.config.URL); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(filepath.Join(c.config.Directory, ".git")); err == nil {
+		if _, err = c.run(context.Background(), "remote", "set-url", "origin", c.config.URL); err != nil {
+			return err
+		}
+		c.cloned = true
+		return nil
+	}
+
+	c.l.Logf(logger.InfoLevel, "Cloning %s...", c.config.URL)
+	if err := os.MkdirAll(filepath.Dir(c.config.Directory), 0700); err != nil {
+		return err
+	}
+	_, err := run(context.Background(), c.config, filepath.Dir(c.config.Directory), "clone", "--branch", c.config.Branch,
+		"--", c.config.URL, c.config.Directory)
+	if err != nil {
+		return err
+	}
+	c.cloned = true
+	return nil
+}
+
+// localPath converts the vault path to the path in the clone
+func (c *Client) localPath(path string) string {
+	return filepath.Join(c.config.Directory, filepath.FromSlash(filepath.Clean("/"+path)))
+}
+
+func (c *Client) Read(path string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.ensureClonedUnsafe(); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(c.localPath(path))
+}
+
+func (c *Client) List(path string) ([]os.FileInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.ensureClonedUnsafe(); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(c.localPath(path))
+	if err != nil {
+		return nil, err
+	}
+	var result []os.FileInfo
+	for _, e := range entries {
+		if e.Name() == ".git" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// Write changes the file in the clone and stages it. The change is committed and pushed by Commit
+func (c *Client) Write(path string, content []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.ensureClonedUnsafe(); err != nil {
+		return err
+	}
+	fileName := c.localPath(path)
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(fileName, content, 0644); err != nil {
+		return err
+	}
+	_, err := c.run(context.Background(), "add", "--", fileName)
+	return err
+}
+
+func (c *Client) Walk(root string, fn filepath.WalkFunc) error {
+	c.mu.Lock()
+	if err := c.ensureClonedUnsafe(); err != nil {
+		c.mu.Unlock()
+		return fn(root, nil, err)
+	}
+	c.mu.Unlock()
+
+	// the walked paths keep the form of the root, so they match the paths of the vault
+	base := c.localPath(root)
+	return filepath.Walk(base, func(path string, info fs.FileInfo, err error) error {
+		rel, relErr := filepath.Rel(base, path)
+		if relErr != nil {
+			return relErr
+		}
+		if info != nil && info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		return fn(filepath.Join(root, rel), info, err)
+	})
+}
+
+// Commit records the staged changes and pushes them to the remote. The commit is kept locally if the push fails, it
+// is pushed on the next synchronization
+func (c *Client) Commit(message string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ctx := context.Background()
+	if _, err := c.run(ctx, "diff", "--cached", "--quiet"); err == nil {
+		return nil
+	}
+	if _, err := c.run(ctx, "commit", "--quiet", "-m", message); err != nil {
+		return err
+	}
+	if err := c.pushUnsafe(ctx); err != nil {
+		c.l.Logf(logger.WarnLevel, "Push '%s' failed: %s", message, err)
+	}
+	return nil
+}
+
+// pushUnsafe pushes local commits, the remote changes are rebased first if the push is rejected
+func (c *Client) pushUnsafe(ctx context.Context) error {
+	if _, err := c.run(ctx, "push", "--quiet", "origin", "HEAD:"+c.config.Branch); err == nil {
+		return nil
+	}
+	if _, err := c.pullUnsafe(ctx); err != nil {
+		return err
+	}
+	_, err := c.run(ctx, "push", "--quiet", "origin", "HEAD:"+c.config.Branch)
+	return err
+}
+
+// pullUnsafe rebases local commits onto the remote branch and returns notes changed by the remote
+func (c *Client) pullUnsafe(ctx context.Context) ([]string, error) {
+	before, err := c.run(ctx, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = c.run(ctx, "pull", "--quiet", "--rebase", "--autostash", "origin", c.config.Branch); err != nil {
+		_, _ = c.run(ctx, "rebase", "--abort")
+		return nil, err
+	}
+	after, err := c.run(ctx, "rev-parse", "HEAD")
+	if err != nil || before == after {
+		return nil, err
+	}
+
+	out, err := c.run(ctx, "diff", "--name-only", before, after, "--", "*.md")
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// Sync pulls the remote changes and pushes not yet pushed commits. It returns notes changed by the remote
+func (c *Client) Sync(ctx context.Context) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.ensureClonedUnsafe(); err != nil {
+		return nil, err
+	}
+	changed, err := c.pullUnsafe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ahead, err := c.run(ctx, "rev-list", "--count", "origin/"+c.config.Branch+"..HEAD")
+	if err == nil && ahead != "0" {
+		err = c.pushUnsafe(ctx)
+	}
+	return changed, err
+}
+
+// Error is a failed git command
+type Error struct {
+	Args   []string
+	Err    error
+	Stderr string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("git %s: %s: %s", strings.Join(e.Args, " "), e.Err, e.Stderr)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// isAuthError detects the rejected credentials by the git output
+func isAuthError(err error) bool {
+	var gitErr *Error
+	if !errors.As(err, &gitErr) {
+		return false
+	}
+	for _, s := range []string{"Authentication failed", "could not read Username", "Permission denied", "403", "401"} {
+		if strings.Contains(gitErr.Stderr, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package git
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/vault"
+	"go-micro.dev/v4/logger"
+)
+
+// SyncInterval is a period of pulling the remote changes
+const SyncInterval = 5 * time.Minute
+
+type Watcher struct {
+	c      *Client
+	ch     chan struct{}
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+	synced bool
+	l      logger.Logger
+}
+
+// Watch synchronizes the clone with the remote on schedule. The watcher fires when the remote commits change notes
+func (c *Client) Watch(path string) vault.Watcher {
+	w := &Watcher{
+		c:  c,
+		ch: make(chan struct{}),
+		l:  c.l,
+	}
+
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.process()
+	}()
+
+	return w
+}
+
+func (w *Watcher) OnChanged() <-chan struct{} {
+	return w.ch
+}
+
+func (w *Watcher) Stop() {
+	w.cancel()
+	w.wg.Wait()
+	close(w.ch)
+}
+
+func (w *Watcher) process() {
+	w.watch()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-time.After(SyncInterval):
+			w.watch()
+		}
+	}
+}
+
+func (w *Watcher) watch() {
+	w.l.Log(logger.DebugLevel, "Pull changes...")
+
+	changed, err := w.c.Sync(w.ctx)
+	if err != nil {
+		if w.ctx.Err() == nil {
+			w.l.Logf(logger.ErrorLevel, "Pull changes failed: %s", err)
+		}
+		return
+	}
+	w.l.Log(logger.DebugLevel, "Pull DONE.")
+
+	// the first synchronization is reported, so the changes made before watching are not missed
+	if len(changed) != 0 || !w.synced {
+		w.l.Logf(logger.InfoLevel, "Notes changed: %d", len(changed))
+		w.synced = true
+		select {
+		case w.ch <- struct{}{}:
+		case <-w.ctx.Done():
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// NotificationSettings contains personal notification preferences of the user
+type NotificationSettings struct {
+	TelegramUser int32 `gorm:"primaryKey"`
+	// RemindBefore is a comma separated list of days before the due date to send advance reminders, e.g. "3,1"
+	RemindBefore string
+	// Digest enables a single daily agenda message instead of a message per task
+	Digest bool
+}
+
+// SentReminder is a record about an advance reminder which has been already sent. The reminder at the exact time of the
+// task has zero DaysBefore and the time of the reminder as DueDate
+type SentReminder struct {
+	TelegramUser int32     `gorm:"primaryKey"`
+	TaskID       string    `gorm:"primaryKey"`
+	DueDate      time.Time `gorm:"primaryKey"`
+	DaysBefore   int       `gorm:"primaryKey"`
+	SentAt       time.Time
+}
+
+// ReminderStats counts reminders about the overdue task for the escalation
+type ReminderStats struct {
+	TelegramUser    int32     `gorm:"primaryKey"`
+	TaskID          string    `gorm:"primaryKey"`
+	DueDate         time.Time `gorm:"primaryKey"`
+	Count           int
+	FirstRemindedAt time.Time
+	LastRemindedAt  time.Time
+}
+
+// SnoozeStats counts how many times the task has been snoozed
+type SnoozeStats struct {
+	TelegramUser  int32  `gorm:"primaryKey"`
+	TaskID        string `gorm:"primaryKey"`
+	Count         int
+	LastSnoozedAt time.Time
+}
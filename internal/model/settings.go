@@ -0,0 +1,14 @@
+package model
+
+// UserSettings contains personal settings of the user. Empty fields fall back to the global settings
+type UserSettings struct {
+	TelegramUser     int32 `gorm:"primaryKey"`
+	Directory        *string
+	NotesDirectory   *string
+	TasksFile        *string
+	NotificationTime *uint32
+	// TimeZone is an IANA time zone name, e.g. "Europe/Moscow"
+	TimeZone *string
+	// CompleteSubtasks enables completion of the open subtasks together with the parent task
+	CompleteSubtasks *bool
+}
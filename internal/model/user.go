@@ -1,8 +1,38 @@
 package model
 
+// Storage backends of the vaults
+const (
+	BackendNextcloud = "nextcloud"
+	BackendWebDAV    = "webdav"
+	BackendS3        = "s3"
+	BackendGit       = "git"
+	BackendSFTP      = "sftp"
+)
+
 type NotesUser struct {
 	TelegramUser int32 `gorm:"primaryKey"`
-	Endpoint     string
-	Login        string
-	Password     string
+	// Backend is a type of the vault storage, Nextcloud is used if it is empty
+	Backend  string
+	Endpoint string
+	Login    string
+	Password string
+	// AppPassword is set if the password has been issued by the Nextcloud Login Flow and should be revoked on logout
+	AppPassword bool
+	// Token is a bearer token of the WebDAV server, it is used instead of the login and the password
+	Token string
+	// Headers are custom HTTP headers of the WebDAV requests
+	Headers map[string]string `gorm:"serializer:json"`
+	// Bucket, Prefix and Region locate the vault in the S3 storage, the login and the password are the access keys
+	Bucket string
+	Prefix string
+	Region string
+	// Branch is a synchronized branch of the git repository
+	Branch string
+	// PrivateKey is a PEM encoded SSH key of the SFTP server, the password is used as its passphrase
+	PrivateKey string
+	// HostKey is a pinned public key of the SFTP server in the authorized_keys format
+	HostKey string
+	// Locked is set if the stored secrets cannot be decrypted by the current key. The vaults using them are disabled
+	// until the next login
+	Locked bool `gorm:"-"`
 }
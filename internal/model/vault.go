@@ -0,0 +1,35 @@
+package model
+
+// NotesVault is a named Obsidian vault of the user. The vault without own endpoint uses the user credentials, empty
+// location fields (bucket, prefix, region, branch) fall back to the user ones and empty paths fall back to the user
+// settings. Empty backend means the backend of the user. The connection fields have the same meaning as in NotesUser
+type NotesVault struct {
+	ID             uint   `gorm:"primaryKey"`
+	TelegramUser   int32  `gorm:"uniqueIndex:idx_user_vault"`
+	Name           string `gorm:"uniqueIndex:idx_user_vault"`
+	Default        bool
+	Backend        string
+	Endpoint       string
+	Login          string
+	Password       string
+	Token          string
+	Headers        map[string]string `gorm:"serializer:json"`
+	Bucket         string
+	Prefix         string
+	Region         string
+	Branch         string
+	PrivateKey     string
+	HostKey        string
+	Directory      string
+	NotesDirectory string
+	TasksFile      string
+	// Locked is set if the stored secrets cannot be decrypted by the current key, see NotesUser
+	Locked bool `gorm:"-"`
+}
+
+// VaultMember is a user who shares the vault with its owner. Alias is a name used to assign tasks, e.g. "@alice"
+type VaultMember struct {
+	VaultID      uint  `gorm:"primaryKey"`
+	TelegramUser int32 `gorm:"primaryKey"`
+	Alias        string
+}
@@ -0,0 +1,8 @@
+package nextcloud
+
+import "github.com/RacoonMediaServer/rms-notes/internal/webdav"
+
+// Check verifies the endpoint and the credentials by the PROPFIND request of the vault directory
+func Check(config WebDAV, directory string) error {
+	return webdav.Check(config.config(), directory)
+}
@@ -0,0 +1,178 @@
+package nextcloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/vault"
+)
+
+const (
+	loginFlowPath   = "/index.php/login/v2"
+	appPasswordPath = "/ocs/v2.php/core/apppassword"
+	davPath         = "/remote.php/dav"
+
+	// LoginFlowTimeout is a lifetime of the login flow on the Nextcloud side
+	LoginFlowTimeout = 20 * time.Minute
+
+	requestTimeout = 30 * time.Second
+)
+
+// ErrLoginPending means the user has not granted access yet
+var ErrLoginPending = errors.New("login is pending")
+
+// LoginFlow is a started Nextcloud Login Flow v2
+type LoginFlow struct {
+	// LoginURL is a page where the user grants access to the service
+	LoginURL string
+
+	token    string
+	endpoint string
+	client   *http.Client
+}
+
+// AppCredentials is a result of the login flow
+type AppCredentials struct {
+	Server      string `json:"server"`
+	Login       string `json:"loginName"`
+	AppPassword string `json:"appPassword"`
+}
+
+type loginFlowResponse struct {
+	Poll struct {
+		Token    string `json:"token"`
+		Endpoint string `json:"endpoint"`
+	} `json:"poll"`
+	Login string `json:"login"`
+}
+
+// DavEndpoint returns the WebDAV endpoint of the Nextcloud server
+func DavEndpoint(server string) string {
+	return strings.TrimSuffix(server, "/") + davPath
+}
+
+// ServerOf returns the Nextcloud server address of the WebDAV endpoint
+func ServerOf(endpoint string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(endpoint, "/"), davPath)
+}
+
+// StartLoginFlow initiates the Login Flow v2 on the server
+func StartLoginFlow(ctx context.Context, server string) (*LoginFlow, error) {
+	client := &http.Client{Timeout: requestTimeout}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(server, "/")+loginFlowPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", vault.ErrUnreachable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: unexpected status %d", vault.ErrUnreachable, resp.StatusCode)
+	}
+
+	var flow loginFlowResponse
+	if err = json.NewDecoder(resp.Body).Decode(&flow); err != nil {
+		return nil, fmt.Errorf("decode login flow failed: %w", err)
+	}
+	if flow.Login == "" || flow.Poll.Token == "" || flow.Poll.Endpoint == "" {
+		return nil, errors.New("invalid login flow response")
+	}
+
+	return &LoginFlow{
+		LoginURL: flow.Login,
+		token:    flow.Poll.Token,
+		endpoint: flow.Poll.Endpoint,
+		client:   client,
+	}, nil
+}
+
+// Poll checks once whether the user has granted access. ErrLoginPending is returned until it happens
+func (f *LoginFlow) Poll(ctx context.Context) (*AppCredentials, error) {
+	form := url.Values{"token": {f.token}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", vault.ErrUnreachable, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrLoginPending
+	default:
+		return nil, fmt.Errorf("%w: unexpected status %d", vault.ErrUnreachable, resp.StatusCode)
+	}
+
+	var credentials AppCredentials
+	if err = json.NewDecoder(resp.Body).Decode(&credentials); err != nil {
+		return nil, fmt.Errorf("decode app credentials failed: %w", err)
+	}
+	if credentials.Login == "" || credentials.AppPassword == "" {
+		return nil, errors.New("invalid app credentials")
+	}
+	return &credentials, nil
+}
+
+// Wait polls the server until the user grants access, the context is cancelled or the flow expires
+func (f *LoginFlow) Wait(ctx context.Context, interval time.Duration) (*AppCredentials, error) {
+	ctx, cancel := context.WithTimeout(ctx, LoginFlowTimeout)
+	defer cancel()
+
+	for {
+		credentials, err := f.Poll(ctx)
+		if err != nil && ctx.Err() != nil {
+			// the poll interrupted by the context is not a failure of the server
+			return nil, ctx.Err()
+		}
+		if !errors.Is(err, ErrLoginPending) {
+			return credentials, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// RevokeAppPassword deletes the app password on the server
+func RevokeAppPassword(ctx context.Context, server, login, appPassword string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, strings.TrimSuffix(server, "/")+appPasswordPath, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(login, appPassword)
+	req.Header.Set("OCS-APIRequest", "true")
+
+	client := &http.Client{Timeout: requestTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %s", vault.ErrUnreachable, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return vault.ErrInvalidCredentials
+	default:
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+}
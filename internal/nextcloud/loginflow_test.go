@@ -0,0 +1,197 @@
+package nextcloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/vault"
+)
+
+const (
+	testToken       = "poll-token"
+	testLogin       = "alice"
+	testAppPassword = "app-password"
+)
+
+// testServer is a stand-in of the Nextcloud login flow and app password endpoints
+type testServer struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// pending is a number of polls answered with 404 before the access is granted
+	pending int
+	polls   int
+	revoked bool
+}
+
+func newTestServer(t *testing.T, pending int) *testServer {
+	s := &testServer{pending: pending}
+	mux := http.NewServeMux()
+	mux.HandleFunc(loginFlowPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		resp := loginFlowResponse{Login: s.URL + "/login/v2/flow/" + testToken}
+		resp.Poll.Token = testToken
+		resp.Poll.Endpoint = s.URL + loginFlowPath + "/poll"
+		_ = json.NewEncoder(w).Encode(&resp)
+	})
+	mux.HandleFunc(loginFlowPath+"/poll", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if r.Method != http.MethodPost || r.FormValue("token") != testToken {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		s.polls++
+		if s.polls <= s.pending {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(&AppCredentials{Server: s.URL, Login: testLogin, AppPassword: testAppPassword})
+	})
+	mux.HandleFunc(appPasswordPath, func(w http.ResponseWriter, r *http.Request) {
+		login, password, ok := r.BasicAuth()
+		if r.Method != http.MethodDelete || r.Header.Get("OCS-APIRequest") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !ok || login != testLogin || password != testAppPassword {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.mu.Lock()
+		s.revoked = true
+		s.mu.Unlock()
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestLoginFlow(t *testing.T) {
+	s := newTestServer(t, 2)
+	ctx := context.Background()
+
+	flow, err := StartLoginFlow(ctx, s.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flow.LoginURL != s.URL+"/login/v2/flow/"+testToken {
+		t.Errorf("unexpected login url: %s", flow.LoginURL)
+	}
+	if _, err = flow.Poll(ctx); !errors.Is(err, ErrLoginPending) {
+		t.Fatalf("expected pending login, got %v", err)
+	}
+
+	credentials, err := flow.Wait(ctx, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := AppCredentials{Server: s.URL, Login: testLogin, AppPassword: testAppPassword}
+	if *credentials != want {
+		t.Errorf("got %+v, want %+v", *credentials, want)
+	}
+	if s.polls != 3 {
+		t.Errorf("got %d polls, want 3", s.polls)
+	}
+}
+
+func TestLoginFlowCancelled(t *testing.T) {
+	s := newTestServer(t, 1000)
+	flow, err := StartLoginFlow(context.Background(), s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err = flow.Wait(ctx, time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline, got %v", err)
+	}
+}
+
+func TestStartLoginFlowErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		unreachable bool
+	}{
+		{
+			name:        "status",
+			handler:     func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) },
+			unreachable: true,
+		},
+		{
+			name:    "not json",
+			handler: func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte("<html>")) },
+		},
+		{
+			name: "no token",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(`{"login": "https://cloud/login"}`))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := httptest.NewServer(tt.handler)
+			defer s.Close()
+
+			_, err := StartLoginFlow(context.Background(), s.URL)
+			if err == nil {
+				t.Fatal("login flow is started")
+			}
+			if errors.Is(err, vault.ErrUnreachable) != tt.unreachable {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+
+	s := httptest.NewServer(http.NotFoundHandler())
+	s.Close()
+	if _, err := StartLoginFlow(context.Background(), s.URL); !errors.Is(err, vault.ErrUnreachable) {
+		t.Errorf("expected unreachable server, got %v", err)
+	}
+}
+
+func TestRevokeAppPassword(t *testing.T) {
+	s := newTestServer(t, 0)
+	ctx := context.Background()
+
+	if err := RevokeAppPassword(ctx, s.URL, testLogin, "wrong"); !errors.Is(err, vault.ErrInvalidCredentials) {
+		t.Errorf("expected invalid credentials, got %v", err)
+	}
+	if err := RevokeAppPassword(ctx, s.URL, testLogin, testAppPassword); err != nil {
+		t.Fatal(err)
+	}
+	if !s.revoked {
+		t.Errorf("app password is not revoked")
+	}
+}
+
+func TestDavEndpoint(t *testing.T) {
+	tests := []struct {
+		server   string
+		endpoint string
+	}{
+		{"https://cloud.example.com", "https://cloud.example.com/remote.php/dav"},
+		{"https://example.com/nextcloud/", "https://example.com/nextcloud/remote.php/dav"},
+	}
+	for _, tt := range tests {
+		if got := DavEndpoint(tt.server); got != tt.endpoint {
+			t.Errorf("got '%s', want '%s'", got, tt.endpoint)
+		}
+		if got := ServerOf(tt.endpoint + "/"); got != strings.TrimSuffix(tt.server, "/") {
+			t.Errorf("got '%s', want '%s'", got, tt.server)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package obsidian
+
+// UnblockHandler is called when tasks become unblocked after the completion of the task they depend on
+type UnblockHandler func(tasks []*Task)
+
+// OnUnblocked sets the handler of the unblocked tasks
+func (v *Vault) OnUnblocked(fn UnblockHandler) {
+	v.unblockHandler.Store(&fn)
+}
+
+func isFinished(t *Task) bool {
+	return !t.IsOpen()
+}
+
+// isBlockedUnsafe checks if the task depends on unfinished tasks. Unknown dependencies don't block the task
+func (v *Vault) isBlockedUnsafe(t *Task) bool {
+	for _, id := range t.DependsOn {
+		if blocker, ok := v.deps[id]; ok && !isFinished(blocker) {
+			return true
+		}
+	}
+	return false
+}
+
+// dependentsUnsafe returns all unfinished tasks of the vault which depend on the task with the id
+func (v *Vault) dependentsUnsafe(id string) []*Task {
+	var result []*Task
+	for _, n := range v.notes {
+		for _, t := range n.tasks {
+			if isFinished(t) {
+				continue
+			}
+			for _, dep := range t.DependsOn {
+				if dep == id {
+					result = append(result, t)
+					break
+				}
+			}
+		}
+	}
+	return result
+}
+
+// finishUnsafe moves the task to the finished status in the index and returns copies of the indexed dependent tasks which
+// become unblocked
+func (v *Vault) finishUnsafe(t *Task, status rune) []*Task {
+	t.setStatus(status, dateOf(v.now()))
+
+	if t.DependencyID == "" || v.deps[t.DependencyID] != t {
+		return nil
+	}
+
+	var unblocked []*Task
+	for _, dependent := range v.dependentsUnsafe(t.DependencyID) {
+		if v.tasks[dependent.ID()] == dependent && !v.isBlockedUnsafe(dependent) {
+			copy := *dependent
+			unblocked = append(unblocked, &copy)
+		}
+	}
+	return unblocked
+}
+
+func (v *Vault) notifyUnblocked(tasks []*Task) {
+	if len(tasks) == 0 {
+		return
+	}
+	if fn := v.unblockHandler.Load(); fn != nil && *fn != nil {
+		go (*fn)(tasks)
+	}
+}
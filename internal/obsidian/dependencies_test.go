@@ -0,0 +1,77 @@
+package obsidian
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseDependencies(t *testing.T) {
+	tests := []struct {
+		line      string
+		id        string
+		dependsOn []string
+		text      string
+	}{
+		{"- [ ] buy paint 🆔 paint", "paint", nil, "buy paint"},
+		{"- [ ] paint walls ⛔ paint", "", []string{"paint"}, "paint walls"},
+		{"- [ ] paint walls ⛔ paint,tools 📅 2026-10-20", "", []string{"paint", "tools"}, "paint walls"},
+		{"- [ ] paint walls 🆔 walls ⛔ paint, tools", "walls", []string{"paint", "tools"}, "paint walls"},
+		{"- [ ] paint walls [id:: walls] [dependsOn:: paint, tools]", "walls", []string{"paint", "tools"}, "paint walls"},
+		{"- [ ] paint walls", "", nil, "paint walls"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			task := ParseTask(tt.line)
+			if task.DependencyID != tt.id {
+				t.Errorf("id: got '%s', want '%s'", task.DependencyID, tt.id)
+			}
+			if !reflect.DeepEqual(task.DependsOn, tt.dependsOn) {
+				t.Errorf("depends on: got %v, want %v", task.DependsOn, tt.dependsOn)
+			}
+			if task.Text != tt.text {
+				t.Errorf("text: got '%s', want '%s'", task.Text, tt.text)
+			}
+		})
+	}
+}
+
+func TestBlockedTasks(t *testing.T) {
+	v, _ := newTestVault(t, map[string]string{
+		"home.md": "- [ ] buy paint 🆔 paint ^a\n- [x] buy tools 🆔 tools ^b\n" +
+			"- [ ] paint walls ⛔ paint, tools ^c\n- [ ] clean up ⛔ tools ^d\n- [ ] relax ⛔ unknown ^e\n",
+	})
+	if err := v.Refresh(All); err != nil {
+		t.Fatal(err)
+	}
+
+	blocked := map[string]bool{"a": false, "b": false, "c": true, "d": false, "e": false}
+	for id, want := range blocked {
+		task, ok := v.GetTask(id)
+		if !ok {
+			t.Fatalf("task '%s' is not found", id)
+		}
+		if task.Blocked != want {
+			t.Errorf("task '%s': blocked is %t, want %t", id, task.Blocked, want)
+		}
+	}
+
+	unblocked := make(chan []*Task, 1)
+	v.OnUnblocked(func(tasks []*Task) { unblocked <- tasks })
+	if err := v.DoneTask("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case tasks := <-unblocked:
+		if len(tasks) != 1 || tasks[0].ID() != "c" {
+			t.Errorf("unexpected unblocked tasks: %v", tasks)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("unblocked tasks are not notified")
+	}
+	if task, _ := v.GetTask("c"); task.Blocked {
+		t.Errorf("task is still blocked")
+	}
+}
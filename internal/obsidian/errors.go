@@ -1,9 +1,13 @@
 package obsidian
 
 import (
+	"errors"
 	"fmt"
 )
 
+// ErrVaultClosed is returned when the vault is modified after its context has been cancelled
+var ErrVaultClosed = errors.New("vault is closed")
+
 type ErrorKind int
 
 const (
@@ -12,6 +16,8 @@ const (
 	ErrSnoozeTaskFailed
 	ErrRemoveTaskFailed
 	ErrDoneTaskFailed
+	ErrAssignIdFailed
+	ErrSetTaskStatusFailed
 )
 
 type Error struct {
@@ -33,11 +39,37 @@ func (e *Error) Error() string {
 		prefix = fmt.Sprintf("remove task '%s' failed", e.Item)
 	case ErrDoneTaskFailed:
 		prefix = fmt.Sprintf("done task '%s' failed", e.Item)
+	case ErrSetTaskStatusFailed:
+		prefix = fmt.Sprintf("set status of task '%s' failed", e.Item)
+	case ErrAssignIdFailed:
+		prefix = fmt.Sprintf("assign task ids in '%s' failed", e.Item)
 	}
 
 	return fmt.Sprintf("%s: %s", prefix, e.Err)
 }
 
+// describeChange returns a summary of the modification, e.g. for the commit message
+func describeChange(kind ErrorKind, item string) string {
+	switch kind {
+	case ErrAddNoteFailed:
+		return fmt.Sprintf("Add note '%s'", item)
+	case ErrAddTaskFailed:
+		return fmt.Sprintf("Add task '%s'", item)
+	case ErrSnoozeTaskFailed:
+		return fmt.Sprintf("Snooze task '%s'", item)
+	case ErrRemoveTaskFailed:
+		return fmt.Sprintf("Remove task '%s'", item)
+	case ErrDoneTaskFailed:
+		return fmt.Sprintf("Done task '%s'", item)
+	case ErrSetTaskStatusFailed:
+		return fmt.Sprintf("Set status of task '%s'", item)
+	case ErrAssignIdFailed:
+		return fmt.Sprintf("Assign task ids in '%s'", item)
+	default:
+		return fmt.Sprintf("Update '%s'", item)
+	}
+}
+
 func makeError(kind ErrorKind, err error, item string) error {
 	if err == nil {
 		return nil
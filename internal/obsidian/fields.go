@@ -0,0 +1,328 @@
+package obsidian
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldsFormat is a style of the task fields in the note
+type FieldsFormat int
+
+const (
+	// FormatEmoji is a default style of the Tasks plugin, e.g. "📅 2026-10-20"
+	FormatEmoji FieldsFormat = iota
+	// FormatDataview is a style of the Dataview inline fields, e.g. "[due:: 2026-10-20]"
+	FormatDataview
+)
+
+// fieldStyle is a style of the single field in the task line
+type fieldStyle int
+
+const (
+	styleEmoji fieldStyle = iota
+	// styleSquare is a Dataview field in square brackets, e.g. "[due:: 2026-10-20]"
+	styleSquare
+	// styleRound is a Dataview field in round brackets, e.g. "(due:: 2026-10-20)"
+	styleRound
+)
+
+const (
+	placeholderStart = "\uE000"
+	placeholderEnd   = "\uE001"
+)
+
+// placedField is a field found in the task line, it is written back at the same place in the same style
+type placedField struct {
+	key   string
+	style fieldStyle
+}
+
+var (
+	inlineFieldRegex = regexp.MustCompile(`[\[(]([a-zA-Z][\w -]*?)::\s*([^\])]*?)\s*[\])]`)
+	tagRegex         = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_/-]*[\p{L}_/-][\p{L}\p{N}_/-]*)`)
+	assigneeRegex    = regexp.MustCompile(`(?:^|\s)@([\p{L}\p{N}_.-]*[\p{L}\p{N}_])`)
+)
+
+var priorityNames = map[string]Priority{
+	"lowest":  PriorityLowest,
+	"low":     PriorityLow,
+	"medium":  PriorityMedium,
+	"high":    PriorityHigh,
+	"highest": PriorityHighest,
+}
+
+type taskField struct {
+	emoji string
+	key   string
+	value string
+}
+
+func (p Priority) name() string {
+	for name, priority := range priorityNames {
+		if priority == p {
+			return name
+		}
+	}
+	return ""
+}
+
+func parseTags(text string) []string {
+	var tags []string
+	for _, found := range tagRegex.FindAllStringSubmatch(text, -1) {
+		tags = append(tags, found[1])
+	}
+	return tags
+}
+
+func parseAssignees(text string) []string {
+	var assignees []string
+	for _, found := range assigneeRegex.FindAllStringSubmatch(text, -1) {
+		assignees = append(assignees, found[1])
+	}
+	return assignees
+}
+
+// place remembers the field found in the line and returns a placeholder which replaces the field in the line
+func (t *Task) place(key string, style fieldStyle) string {
+	t.placed = append(t.placed, placedField{key: key, style: style})
+	return " " + placeholderStart + strconv.Itoa(len(t.placed)-1) + placeholderEnd + " "
+}
+
+// placeholderIndex returns the index of the placed field if the word is a placeholder
+func placeholderIndex(word string) (int, bool) {
+	if !strings.HasPrefix(word, placeholderStart) || !strings.HasSuffix(word, placeholderEnd) {
+		return 0, false
+	}
+	idx, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(word, placeholderStart), placeholderEnd))
+	return idx, err == nil
+}
+
+// extractInlineFields parses Dataview inline fields of the task and replaces them with placeholders. The fields known
+// by the Tasks plugin fill the task attributes, the other ones are kept in Fields
+func (t *Task) extractInlineFields(line string) string {
+	return inlineFieldRegex.ReplaceAllStringFunc(line, func(field string) string {
+		found := inlineFieldRegex.FindStringSubmatch(field)
+		key, value := strings.TrimSpace(found[1]), found[2]
+		style := styleSquare
+		if field[0] == '(' {
+			style = styleRound
+		}
+		if !t.applyField(key, value) {
+			if t.Fields == nil {
+				t.Fields = map[string]string{}
+			}
+			t.Fields[key] = value
+			t.fieldKeys = append(t.fieldKeys, key)
+		}
+		return t.place(key, style)
+	})
+}
+
+// setLayout keeps the line with placeholders to write the fields back at their places. The fields added later are
+// written in the Dataview style only if the line has no emoji fields
+func (t *Task) setLayout(line string) {
+	if len(t.placed) == 0 {
+		t.Text = strings.Join(strings.Fields(line), " ")
+		return
+	}
+	t.layout = strings.Join(strings.Fields(line), " ")
+	t.Text = t.layoutText()
+
+	emoji, dataview := 0, 0
+	for _, f := range t.placed {
+		if _, custom := t.Fields[f.key]; custom {
+			continue
+		}
+		if f.style == styleEmoji {
+			emoji++
+		} else {
+			dataview++
+		}
+	}
+	if dataview != 0 && emoji == 0 {
+		t.Format = FormatDataview
+	}
+}
+
+func (t *Task) applyField(key, value string) bool {
+	parseDate := func(dest **time.Time) bool {
+		date, err := time.Parse(DateFormat, value)
+		if err != nil {
+			return false
+		}
+		*dest = &date
+		return true
+	}
+
+	switch key {
+	case "due":
+		return parseDate(&t.DueDate)
+	case "scheduled":
+		return parseDate(&t.ScheduledDate)
+	case "start":
+		return parseDate(&t.StartDate)
+	case "created":
+		return parseDate(&t.CreatedDate)
+	case "completion":
+		return parseDate(&t.DoneDate)
+	case "cancelled":
+		return parseDate(&t.CancelledDate)
+	case "time":
+		tm, err := parseTime(value)
+		if err == nil {
+			t.Time = &tm
+		}
+		return err == nil
+	case "priority":
+		p, ok := priorityNames[strings.ToLower(value)]
+		if ok {
+			t.Priority = p
+		}
+		return ok
+	case "repeat":
+		r, err := ParseRecurrence(value)
+		if err == nil {
+			t.Recurrent = r
+		}
+		return err == nil
+	case "id":
+		t.DependencyID = value
+		return value != ""
+	case "dependsOn":
+		for _, id := range strings.Split(value, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				t.DependsOn = append(t.DependsOn, id)
+			}
+		}
+		return len(t.DependsOn) != 0
+	}
+	return false
+}
+
+// taskFields returns the task attributes in the order of the Tasks plugin
+func (t Task) taskFields() []taskField {
+	var fields []taskField
+	if t.DependencyID != "" {
+		fields = append(fields, taskField{emoji: "🆔", key: "id", value: t.DependencyID})
+	}
+	if len(t.DependsOn) != 0 {
+		fields = append(fields, taskField{emoji: "⛔", key: "dependsOn", value: strings.Join(t.DependsOn, ",")})
+	}
+	if t.Priority != PriorityNo {
+		fields = append(fields, taskField{emoji: t.Priority.String(), key: "priority", value: t.Priority.name()})
+	}
+	if t.Recurrent != nil {
+		fields = append(fields, taskField{emoji: "🔁", key: "repeat", value: t.Recurrent.Text})
+	}
+	dates := []struct {
+		emoji string
+		key   string
+		date  *time.Time
+	}{
+		{"➕", "created", t.CreatedDate},
+		{"🛫", "start", t.StartDate},
+		{"⏳", "scheduled", t.ScheduledDate},
+		{"📅", "due", t.DueDate},
+		{"❌", "cancelled", t.CancelledDate},
+		{"✅", "completion", t.DoneDate},
+	}
+	for _, d := range dates {
+		if d.date != nil {
+			fields = append(fields, taskField{emoji: d.emoji, key: d.key, value: d.date.Format(DateFormat)})
+		}
+		if d.key == "due" && t.Time != nil {
+			fields = append(fields, taskField{emoji: "⏰", key: "time", value: formatTime(*t.Time)})
+		}
+	}
+	return fields
+}
+
+func (f taskField) format(style fieldStyle) string {
+	switch {
+	case style == styleRound:
+		return "(" + f.key + ":: " + f.value + ")"
+	case style == styleSquare || f.emoji == "":
+		return "[" + f.key + ":: " + f.value + "]"
+	case f.key == "priority":
+		return f.emoji
+	default:
+		return f.emoji + " " + f.value
+	}
+}
+
+// formatBody returns the task text with the fields. The fields found in the line are written at their places in the
+// original style, the new ones are appended in the style of the task
+func (t Task) formatBody() string {
+	fields := map[string]taskField{}
+	var order []string
+	for _, key := range t.customFieldKeys() {
+		fields[key] = taskField{key: key, value: t.Fields[key]}
+		order = append(order, key)
+	}
+	for _, f := range t.taskFields() {
+		fields[f.key] = f
+		order = append(order, f.key)
+	}
+
+	var result []string
+	if t.layout != "" && t.layoutText() == t.Text {
+		for _, word := range strings.Fields(t.layout) {
+			idx, ok := placeholderIndex(word)
+			if !ok {
+				result = append(result, word)
+				continue
+			}
+			p := t.placed[idx]
+			if f, ok := fields[p.key]; ok {
+				result = append(result, f.format(p.style))
+				delete(fields, p.key)
+			}
+		}
+	} else if t.Text != "" {
+		result = append(result, t.Text)
+	}
+
+	style := styleEmoji
+	if t.Format == FormatDataview {
+		style = styleSquare
+	}
+	for _, key := range order {
+		if f, ok := fields[key]; ok {
+			result = append(result, f.format(style))
+		}
+	}
+	return strings.Join(result, " ")
+}
+
+// layoutText returns the text of the task layout, it differs from the task text if the text has been changed
+func (t Task) layoutText() string {
+	var text []string
+	for _, word := range strings.Fields(t.layout) {
+		if _, ok := placeholderIndex(word); !ok {
+			text = append(text, word)
+		}
+	}
+	return strings.Join(text, " ")
+}
+
+// customFieldKeys returns keys of the custom fields in the original order
+func (t Task) customFieldKeys() []string {
+	keys := make([]string, 0, len(t.Fields))
+	seen := map[string]struct{}{}
+	for _, key := range t.fieldKeys {
+		if _, ok := t.Fields[key]; ok {
+			if _, dup := seen[key]; !dup {
+				keys = append(keys, key)
+				seen[key] = struct{}{}
+			}
+		}
+	}
+	for key := range t.Fields {
+		if _, ok := seen[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,167 @@
+package obsidian
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		text      string
+		tags      []string
+		assignees []string
+	}{
+		{"call mom #family #важно", []string{"family", "важно"}, nil},
+		{"#home/garden water plants", []string{"home/garden"}, nil},
+		{"issue #123 and a#b", nil, nil},
+		{"review with @alice and @bob.smith.", nil, []string{"alice", "bob.smith"}},
+		{"mail me@example.com", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := parseTags(tt.text); !reflect.DeepEqual(got, tt.tags) {
+				t.Errorf("tags: got %v, want %v", got, tt.tags)
+			}
+			if got := parseAssignees(tt.text); !reflect.DeepEqual(got, tt.assignees) {
+				t.Errorf("assignees: got %v, want %v", got, tt.assignees)
+			}
+		})
+	}
+}
+
+func TestParseDataviewFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		text   string
+		due    string
+		prio   Priority
+		repeat string
+		fields map[string]string
+		format FieldsFormat
+	}{
+		{
+			name: "square", line: "- [ ] pay rent [due:: 2026-10-20] [priority:: high]",
+			text: "pay rent", due: "2026-10-20", prio: PriorityHigh, format: FormatDataview,
+		},
+		{
+			name: "round", line: "- [ ] pay rent (due:: 2026-10-20) (repeat:: every month)",
+			text: "pay rent", due: "2026-10-20", repeat: "every month", format: FormatDataview,
+		},
+		{
+			name: "custom", line: "- [ ] pay rent [context:: home] [due:: 2026-10-20]",
+			text: "pay rent", due: "2026-10-20", fields: map[string]string{"context": "home"}, format: FormatDataview,
+		},
+		{
+			name: "custom only", line: "- [ ] pay rent [context:: home] 📅 2026-10-20",
+			text: "pay rent", due: "2026-10-20", fields: map[string]string{"context": "home"}, format: FormatEmoji,
+		},
+		{
+			name: "mixed", line: "- [ ] pay rent [priority:: high] 📅 2026-10-20",
+			text: "pay rent", due: "2026-10-20", prio: PriorityHigh, format: FormatEmoji,
+		},
+		{
+			name: "invalid value is kept as custom", line: "- [ ] pay rent [due:: tomorrow]",
+			text: "pay rent", fields: map[string]string{"due": "tomorrow"}, format: FormatEmoji,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := ParseTask(tt.line)
+			if task.Text != tt.text {
+				t.Errorf("text: got '%s', want '%s'", task.Text, tt.text)
+			}
+			var due *string
+			if task.DueDate != nil {
+				s := task.DueDate.Format(DateFormat)
+				due = &s
+			}
+			if (due == nil && tt.due != "") || (due != nil && *due != tt.due) {
+				t.Errorf("due: got %v, want '%s'", task.DueDate, tt.due)
+			}
+			if task.Priority != tt.prio {
+				t.Errorf("priority: got %d, want %d", task.Priority, tt.prio)
+			}
+			repeat := ""
+			if task.Recurrent != nil {
+				repeat = task.Recurrent.Text
+			}
+			if repeat != tt.repeat {
+				t.Errorf("repeat: got '%s', want '%s'", repeat, tt.repeat)
+			}
+			if len(task.Fields) != 0 || len(tt.fields) != 0 {
+				if !reflect.DeepEqual(task.Fields, tt.fields) {
+					t.Errorf("fields: got %v, want %v", task.Fields, tt.fields)
+				}
+			}
+			if task.Format != tt.format {
+				t.Errorf("format: got %d, want %d", task.Format, tt.format)
+			}
+		})
+	}
+}
+
+func TestFieldsRoundTrip(t *testing.T) {
+	lines := []string{
+		"* [ ] pay rent [due:: 2026-10-20] [priority:: high]",
+		"* [ ] pay rent (due:: 2026-10-20) for #home (repeat:: every month)",
+		"* [ ] 📅 2026-10-20 pay rent [context:: home] ⏫ @alice",
+		"* [ ] pay [scheduled:: 2026-10-15] rent 🛫 2026-10-10 ^abc123",
+		"* [ ] pay rent [due:: tomorrow]",
+	}
+	for _, l := range lines {
+		if got := ParseTask(l).String(); got != l {
+			t.Errorf("got '%s', want '%s'", got, l)
+		}
+	}
+}
+
+func TestChangeFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		change func(t *Task)
+		want   string
+	}{
+		{
+			name:   "change in place",
+			line:   "* [ ] pay (due:: 2026-10-20) rent #home",
+			change: func(t *Task) { t.DueDate = date("2026-10-27") },
+			want:   "* [ ] pay (due:: 2026-10-27) rent #home",
+		},
+		{
+			name:   "append in the task style",
+			line:   "* [ ] pay rent [due:: 2026-10-20]",
+			change: func(t *Task) { t.setStatus(StatusSymbolDone, *date("2026-10-19")) },
+			want:   "* [x] pay rent [due:: 2026-10-20] [completion:: 2026-10-19]",
+		},
+		{
+			name:   "append emoji",
+			line:   "* [ ] 📅 2026-10-20 pay rent",
+			change: func(t *Task) { t.setStatus(StatusSymbolDone, *date("2026-10-19")) },
+			want:   "* [x] 📅 2026-10-20 pay rent ✅ 2026-10-19",
+		},
+		{
+			name:   "remove",
+			line:   "* [x] pay ✅ 2026-10-19 rent [context:: home]",
+			change: func(t *Task) { t.setStatus(StatusSymbolTodo, *date("2026-10-19")) },
+			want:   "* [ ] pay rent [context:: home]",
+		},
+		{
+			name:   "changed text",
+			line:   "* [ ] pay (due:: 2026-10-20) rent",
+			change: func(t *Task) { t.Text = "pay the rent" },
+			want:   "* [ ] pay the rent [due:: 2026-10-20]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := ParseTask(tt.line)
+			tt.change(task)
+			if got := task.String(); got != tt.want {
+				t.Errorf("got '%s', want '%s'", got, tt.want)
+			}
+		})
+	}
+}
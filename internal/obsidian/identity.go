@@ -0,0 +1,210 @@
+package obsidian
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	blockIdLength = 6
+	blockIdChars  = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+	// minSimilarity is a threshold of the fuzzy matching of the edited tasks
+	minSimilarity = 0.6
+)
+
+var (
+	blockIdRegex = regexp.MustCompile(`\s\^([a-zA-Z0-9-]+)\s*$`)
+
+	// the task syntax of the first versions, it is used to calculate legacy task ids
+	legacyTaskStart     = regexp.MustCompile(`^\s*(\*|-) \[(x| |X)\]\s*`)
+	legacyDueDateRegex  = regexp.MustCompile(`📅 (\d\d\d\d-\d\d-\d\d)`)
+	legacyDoneDateRegex = regexp.MustCompile(`✅ (\d\d\d\d-\d\d-\d\d)`)
+)
+
+// ID returns a stable identifier of the task. The Obsidian block id is used if the task has it, otherwise the hash of
+// the task line
+func (t Task) ID() string {
+	if t.BlockID != "" {
+		return t.BlockID
+	}
+	return t.Hash()
+}
+
+// lineHash returns the hash of the task line without block id, so the occurrences of the task are compared by their
+// content
+func (t Task) lineHash() string {
+	t.BlockID = ""
+	return t.Hash()
+}
+
+// matchesLegacyHash checks whether the id is the hash which identified the task before block ids had been introduced,
+// so the buttons of already sent notifications keep working. The hash is calculated from the source line of the task.
+// The block id could be assigned after the notification had been sent, so the line is checked without it too
+func (t Task) matchesLegacyHash(id string) bool {
+	line := t.source
+	if hash := legacyHash(line); hash == "" || hash == id {
+		return hash != ""
+	}
+	if extractBlockID(&line) == "" {
+		return false
+	}
+	return legacyHash(line) == id
+}
+
+// legacyHash returns the hash of the line in the canonical form of the first versions: "* [ ]" or "* [x]", the text,
+// the priority, the repetition, the due and the done dates. Only the fields known then are recognized, others are
+// kept in the text. An empty string is returned for the lines which were not tasks then
+func legacyHash(line string) string {
+	found := legacyTaskStart.FindStringSubmatch(line)
+	if len(found) != 3 {
+		return ""
+	}
+	done := found[2] != " "
+	line = strings.Replace(line, found[0], "", 1)
+
+	priority := ""
+	for _, p := range []string{"🔽", "🔼", "⏫"} {
+		if strings.Contains(line, p) {
+			priority = p
+			line = strings.Replace(line, p, "", 1)
+			break
+		}
+	}
+	repetition := ""
+	for _, r := range []string{"🔁 every day", "🔁 every week", "🔁 every month", "🔁 every year"} {
+		if strings.Contains(line, r) {
+			repetition = r
+			line = strings.Replace(line, r, "", 1)
+			break
+		}
+	}
+	extractDate := func(re *regexp.Regexp) string {
+		found := re.FindStringSubmatch(line)
+		if len(found) != 2 {
+			return ""
+		}
+		line = strings.Replace(line, found[0], "", 1)
+		if _, err := time.Parse(DateFormat, found[1]); err != nil {
+			return ""
+		}
+		return found[1]
+	}
+	due := extractDate(legacyDueDateRegex)
+	doneDate := extractDate(legacyDoneDateRegex)
+
+	result := "* [ ] "
+	if done {
+		result = "* [x] "
+	}
+	result += strings.Trim(line, " ")
+	if priority != "" {
+		result += " " + priority
+	}
+	if repetition != "" {
+		result += " " + repetition
+	}
+	if due != "" {
+		result += " 📅 " + due
+	}
+	if doneDate != "" {
+		result += " ✅ " + doneDate
+	}
+
+	h := sha1.Sum([]byte(result))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// deriveBlockID makes the block id from the hash of the seed and the salt
+func deriveBlockID(seed string, salt int) string {
+	h := sha1.Sum([]byte(seed + "\x00" + strconv.Itoa(salt)))
+	id := make([]byte, blockIdLength)
+	for i := range id {
+		id[i] = blockIdChars[int(h[i])%len(blockIdChars)]
+	}
+	return string(id)
+}
+
+func extractBlockID(line *string) string {
+	found := blockIdRegex.FindStringSubmatch(*line)
+	if len(found) != 2 {
+		return ""
+	}
+	*line = strings.TrimSuffix(*line, found[0])
+	return found[1]
+}
+
+// setBlockID appends the block id to the line or replaces the existing one
+func setBlockID(line, id string) string {
+	extractBlockID(&line)
+	return strings.TrimRight(line, " \t") + " ^" + id
+}
+
+// matchTask looks for the task occurrence in the note lines. The task is matched by the block id first. If no line has
+// the id (e.g. the user has removed or changed it) or the id is not written yet, the task is matched by the exact line
+// content and then by the fuzzy similarity of the task text, because the line could be edited since the task had been
+// loaded. The lines with the block ids of other tasks of the note (known) are never matched by the content. The
+// occurrence at the original position of the task wins among equal candidates
+func matchTask(lines []string, target *Task, known map[string]struct{}) (int, *Task) {
+	idx := -1
+	var found *Task
+	bestScore := 0.
+	targetHash := target.lineHash()
+	targetWords := words(target.Text)
+
+	scanTasks(lines, func(i int, t *Task) bool {
+		score := 0.
+		switch {
+		case target.BlockID != "" && t.BlockID == target.BlockID:
+			score = 3.
+		case t.BlockID != "" && t.BlockID != target.replacedID && isKnownID(known, t.BlockID):
+			return true
+		case t.lineHash() == targetHash:
+			score = 2.
+		default:
+			if score = similarity(targetWords, words(t.Text)); score < minSimilarity {
+				return true
+			}
+		}
+		if t.Line == target.Line {
+			score += 0.5
+		}
+		if score > bestScore {
+			idx, found, bestScore = i, t, score
+		}
+		return true
+	})
+
+	return idx, found
+}
+
+func isKnownID(known map[string]struct{}, id string) bool {
+	_, ok := known[id]
+	return ok
+}
+
+func words(text string) map[string]struct{} {
+	result := map[string]struct{}{}
+	for _, w := range strings.Fields(strings.ToLower(text)) {
+		result[w] = struct{}{}
+	}
+	return result
+}
+
+// similarity calculates Jaccard index of the word sets
+func similarity(a, b map[string]struct{}) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1.
+	}
+	common := 0
+	for w := range a {
+		if _, ok := b[w]; ok {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
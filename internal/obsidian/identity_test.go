@@ -0,0 +1,191 @@
+package obsidian
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestDeriveBlockID(t *testing.T) {
+	id := deriveBlockID("note.md\x00\x00hash", 0)
+	if len(id) != blockIdLength {
+		t.Fatalf("unexpected length of '%s'", id)
+	}
+	for _, c := range id {
+		if !strings.ContainsRune(blockIdChars, c) {
+			t.Fatalf("unexpected character in '%s'", id)
+		}
+	}
+	if again := deriveBlockID("note.md\x00\x00hash", 0); again != id {
+		t.Errorf("id is not stable: '%s' != '%s'", again, id)
+	}
+	if salted := deriveBlockID("note.md\x00\x00hash", 1); salted == id {
+		t.Errorf("salt does not change the id")
+	}
+}
+
+func TestExtractBlockID(t *testing.T) {
+	tests := []struct {
+		line string
+		id   string
+		rest string
+	}{
+		{"call mom ^abc123", "abc123", "call mom"},
+		{"call mom ^abc-123  ", "abc-123", "call mom"},
+		{"call mom", "", "call mom"},
+		{"call ^abc mom", "", "call ^abc mom"},
+		{"call mom^abc", "", "call mom^abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			line := tt.line
+			if id := extractBlockID(&line); id != tt.id {
+				t.Errorf("id: got '%s', want '%s'", id, tt.id)
+			}
+			if line != tt.rest {
+				t.Errorf("line: got '%s', want '%s'", line, tt.rest)
+			}
+		})
+	}
+}
+
+func TestSetBlockID(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"- [ ] call mom", "- [ ] call mom ^new"},
+		{"- [ ] call mom  ", "- [ ] call mom ^new"},
+		{"- [ ] call mom ^old", "- [ ] call mom ^new"},
+	}
+	for _, tt := range tests {
+		if got := setBlockID(tt.line, "new"); got != tt.want {
+			t.Errorf("got '%s', want '%s'", got, tt.want)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 1},
+		{"call mom", "call mom", 1},
+		{"Call Mom", "call mom", 1},
+		{"call mom", "call dad", 1. / 3},
+		{"call mom", "buy milk", 0},
+		{"buy milk and bread", "buy milk and eggs", 3. / 5},
+	}
+	for _, tt := range tests {
+		if got := similarity(words(tt.a), words(tt.b)); got != tt.want {
+			t.Errorf("'%s' ~ '%s': got %f, want %f", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMatchTask(t *testing.T) {
+	lines := []string{
+		"# Tasks",
+		"- [ ] buy milk and bread",
+		"- [ ] call mom ^abc123",
+		"- [ ] pay rent",
+		"- [ ] pay rent",
+		"- [ ] copied ^dup",
+		"- [ ] walk the dog ^renamed",
+	}
+	// known contains the block ids of other tasks of the note
+	known := map[string]struct{}{"abc123": {}, "dup": {}}
+
+	// pending makes the task with the assigned but not written block id
+	pending := func(line string, n int, id string) Task {
+		t := *ParseTask(line)
+		t.replacedID, t.BlockID, t.idPending, t.Line = t.BlockID, id, true, n
+		return t
+	}
+	at := func(line string, n int) Task {
+		t := *ParseTask(line)
+		t.Line = n
+		return t
+	}
+
+	tests := []struct {
+		name   string
+		target Task
+		want   int
+	}{
+		{name: "block id", target: at("- [ ] call mom, edited ^abc123", 1), want: 2},
+		{name: "block id of another task", target: at("- [ ] call mom ^zzz", 3), want: -1},
+		{name: "removed block id", target: at("- [ ] pay rent ^gone", 4), want: 3},
+		{name: "changed block id", target: at("- [ ] walk the dog ^walk", 7), want: 6},
+		{name: "exact line", target: at("- [ ] pay rent", 4), want: 3},
+		{name: "position among equal", target: at("- [ ] pay rent", 5), want: 4},
+		{name: "fuzzy", target: at("- [ ] buy milk and eggs", 2), want: 1},
+		{name: "not similar", target: at("- [ ] feed the cat", 2), want: -1},
+		{name: "pending id", target: pending("- [ ] pay rent", 5, "new"), want: 4},
+		{name: "pending id of the changed line", target: pending("- [ ] pay the rent", 4, "new"), want: 3},
+		{name: "pending id replacing a copied one", target: pending("- [ ] copied ^dup", 6, "new"), want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx, task := matchTask(lines, &tt.target, known)
+			if idx != tt.want {
+				t.Fatalf("got %d, want %d", idx, tt.want)
+			}
+			if (task != nil) != (idx >= 0) {
+				t.Errorf("task is %v at %d", task, idx)
+			}
+		})
+	}
+}
+
+func sha1Base64(s string) string {
+	h := sha1.Sum([]byte(s))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+func TestLegacyHash(t *testing.T) {
+	tests := []struct {
+		line string
+		// canonical is the line as it was written by Task.String of the first versions
+		canonical string
+	}{
+		{"- [ ] call mom 📅 2026-10-20", "* [ ] call mom 📅 2026-10-20"},
+		{"* [ ] call mom 📅 2026-10-20", "* [ ] call mom 📅 2026-10-20"},
+		{"\t- [X] call mom", "* [x] call mom"},
+		{"- [x] ✅ 2026-10-19 pay rent ⏫ 📅 2026-10-20", "* [x] pay rent ⏫ 📅 2026-10-20 ✅ 2026-10-19"},
+		{"- [ ] water 🔁 every week plants 🔼", "* [ ] water  plants 🔼 🔁 every week"},
+		{"- [ ] pay 🛫 2026-10-10 rent #home 📅 2026-10-20", "* [ ] pay 🛫 2026-10-10 rent #home 📅 2026-10-20"},
+		{"- [ ] call 📅2026-10-20", "* [ ] call 📅2026-10-20"},
+		{"- [ ] call 📅 2026-13-40", "* [ ] call"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			if got, want := legacyHash(tt.line), sha1Base64(tt.canonical); got != want {
+				t.Errorf("hash of '%s' does not match '%s'", tt.line, tt.canonical)
+			}
+		})
+	}
+
+	if legacyHash("- [/] in progress") != "" {
+		t.Errorf("hash of the status unknown then is calculated")
+	}
+}
+
+func TestMatchesLegacyHash(t *testing.T) {
+	id := sha1Base64("* [ ] call mom 📅 2026-10-20")
+	if !ParseTask("- [ ] call mom 📅 2026-10-20").matchesLegacyHash(id) {
+		t.Errorf("task is not matched")
+	}
+	if !ParseTask("- [ ] call mom 📅 2026-10-20 ^abc123").matchesLegacyHash(id) {
+		t.Errorf("task with the assigned block id is not matched")
+	}
+	if ParseTask("- [ ] call dad 📅 2026-10-20").matchesLegacyHash(id) {
+		t.Errorf("other task is matched")
+	}
+	if (&Task{}).matchesLegacyHash(sha1Base64("")) {
+		t.Errorf("task without source line is matched")
+	}
+}
@@ -12,7 +12,7 @@ func (v *Vault) processPipeline() {
 	for {
 		select {
 		case <-v.ctx.Done():
-			close(v.pipeCh)
+			v.dropPendingJobs()
 			return
 		case fn := <-v.pipeCh:
 			if err := fn(); err != nil {
@@ -24,3 +24,19 @@ func (v *Vault) processPipeline() {
 		}
 	}
 }
+
+// dropPendingJobs discards the jobs queued before the vault has been closed
+func (v *Vault) dropPendingJobs() {
+	dropped := 0
+	for {
+		select {
+		case <-v.pipeCh:
+			dropped++
+		default:
+			if dropped != 0 {
+				v.l.Logf(logger.WarnLevel, "%d pending jobs dropped", dropped)
+			}
+			return
+		}
+	}
+}
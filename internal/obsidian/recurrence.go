@@ -0,0 +1,414 @@
+package obsidian
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	recurrenceRegex = regexp.MustCompile(`🔁 ?([a-zA-Z0-9, ]+)`)
+	ordinalRegex    = regexp.MustCompile(`^(\d+)(st|nd|rd|th)?$`)
+)
+
+type Frequency int
+
+const (
+	Daily Frequency = iota
+	Weekly
+	Monthly
+	Yearly
+)
+
+// Recurrence is a parsed recurrence rule of the Obsidian Tasks plugin, e.g. "every 2 weeks on Monday when done"
+type Recurrence struct {
+	// Text is an original rule text without the 🔁 sign
+	Text      string
+	Frequency Frequency
+	Interval  int
+	// Weekdays limits weekly recurrence to the specified days of week
+	Weekdays []time.Weekday
+	// MonthDay is a day of month for monthly and yearly recurrence (-1 means the last day)
+	MonthDay int
+	// Month is a month of yearly recurrence, e.g. "every year on January 15"
+	Month time.Month
+	// WeekdayOrdinal is a number of Weekdays[0] in the month for monthly recurrence, e.g. "on the 2nd Tuesday" (-1 means the last one)
+	WeekdayOrdinal int
+	// WhenDone means the next occurrence is calculated from the completion date
+	WhenDone bool
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+	"sunday":    time.Sunday,
+}
+
+var monthNames = map[string]time.Month{
+	"january":   time.January,
+	"february":  time.February,
+	"march":     time.March,
+	"april":     time.April,
+	"may":       time.May,
+	"june":      time.June,
+	"july":      time.July,
+	"august":    time.August,
+	"september": time.September,
+	"october":   time.October,
+	"november":  time.November,
+	"december":  time.December,
+}
+
+var frequencyNames = map[string]Frequency{
+	"day":    Daily,
+	"days":   Daily,
+	"week":   Weekly,
+	"weeks":  Weekly,
+	"month":  Monthly,
+	"months": Monthly,
+	"year":   Yearly,
+	"years":  Yearly,
+}
+
+// extractRecurrence cuts the recurrence rule from the line. The rule is the longest sequence of words after 🔁 which
+// is parsed successfully, so the text following the rule is kept in the task. The rule is replaced by the result of mark
+func extractRecurrence(line *string, mark func() string) *Recurrence {
+	loc := recurrenceRegex.FindStringSubmatchIndex(*line)
+	if loc == nil {
+		return nil
+	}
+
+	// ends of the words of the candidate rule in the line
+	var words []string
+	var ends []int
+	for i := loc[2]; i < loc[3]; {
+		for i < loc[3] && (*line)[i] == ' ' {
+			i++
+		}
+		start := i
+		for i < loc[3] && (*line)[i] != ' ' {
+			i++
+		}
+		if i > start {
+			words = append(words, (*line)[start:i])
+			ends = append(ends, i)
+		}
+	}
+
+	for n := len(words); n >= 2; n-- {
+		// the comma separating the rule from the text is dropped together with the rule
+		rule := strings.TrimRight(strings.Join(words[:n], " "), ",")
+		r, err := ParseRecurrence(rule)
+		if err != nil {
+			continue
+		}
+		*line = (*line)[:loc[0]] + mark() + (*line)[ends[n-1]:]
+		return r
+	}
+	return nil
+}
+
+func (r Recurrence) String() string {
+	return "🔁 " + r.Text
+}
+
+// ParseRecurrence parses the rule text, e.g. "every weekday", "every month on the 15th", "every Monday, Thursday"
+func ParseRecurrence(text string) (*Recurrence, error) {
+	text = strings.TrimSpace(text)
+	r := &Recurrence{Text: text, Interval: 1}
+
+	rule := strings.ToLower(text)
+	if strings.HasSuffix(rule, " when done") {
+		r.WhenDone = true
+		rule = strings.TrimSuffix(rule, " when done")
+	}
+
+	tokens := strings.Fields(strings.NewReplacer(",", " , ").Replace(rule))
+	if len(tokens) < 2 || tokens[0] != "every" {
+		return nil, fmt.Errorf("invalid recurrence rule: %s", text)
+	}
+	tokens = tokens[1:]
+
+	if tokens[0] == "other" {
+		r.Interval = 2
+		tokens = tokens[1:]
+	} else if n, err := strconv.Atoi(tokens[0]); err == nil {
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid recurrence interval: %s", text)
+		}
+		r.Interval = n
+		tokens = tokens[1:]
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("invalid recurrence rule: %s", text)
+	}
+
+	switch {
+	case tokens[0] == "weekday":
+		r.Frequency = Weekly
+		r.Weekdays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+		tokens = tokens[1:]
+	case isWeekday(tokens[0]):
+		r.Frequency = Weekly
+		days, rest, err := parseWeekdays(tokens)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recurrence rule '%s': %w", text, err)
+		}
+		r.Weekdays = days
+		tokens = rest
+	default:
+		freq, ok := frequencyNames[tokens[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown recurrence frequency: %s", tokens[0])
+		}
+		r.Frequency = freq
+		tokens = tokens[1:]
+	}
+
+	if len(tokens) != 0 {
+		if err := r.parseModifier(tokens); err != nil {
+			return nil, fmt.Errorf("invalid recurrence rule '%s': %w", text, err)
+		}
+	}
+
+	return r, nil
+}
+
+func (r *Recurrence) parseModifier(tokens []string) error {
+	if tokens[0] != "on" || len(tokens) < 2 {
+		return fmt.Errorf("unexpected '%s'", strings.Join(tokens, " "))
+	}
+	tokens = tokens[1:]
+
+	switch r.Frequency {
+	case Weekly:
+		if r.Weekdays != nil {
+			return fmt.Errorf("days of week specified twice")
+		}
+		days, rest, err := parseWeekdays(tokens)
+		if err != nil {
+			return err
+		}
+		if len(rest) != 0 {
+			return fmt.Errorf("unexpected '%s'", strings.Join(rest, " "))
+		}
+		r.Weekdays = days
+		return nil
+
+	case Monthly:
+		if tokens[0] == "the" {
+			tokens = tokens[1:]
+		}
+		if len(tokens) == 0 {
+			return fmt.Errorf("day of month expected")
+		}
+
+		ordinal := 0
+		if tokens[0] == "last" {
+			ordinal = -1
+		} else if found := ordinalRegex.FindStringSubmatch(tokens[0]); found != nil {
+			ordinal, _ = strconv.Atoi(found[1])
+		} else {
+			return fmt.Errorf("invalid day of month: %s", tokens[0])
+		}
+		tokens = tokens[1:]
+
+		if len(tokens) == 0 || (len(tokens) == 1 && tokens[0] == "day") {
+			if ordinal == 0 || ordinal > 31 {
+				return fmt.Errorf("invalid day of month: %d", ordinal)
+			}
+			r.MonthDay = ordinal
+			return nil
+		}
+		if len(tokens) == 1 && isWeekday(tokens[0]) {
+			if ordinal == 0 || ordinal > 5 {
+				return fmt.Errorf("invalid weekday number: %d", ordinal)
+			}
+			r.WeekdayOrdinal = ordinal
+			r.Weekdays = []time.Weekday{weekdayNames[tokens[0]]}
+			return nil
+		}
+		return fmt.Errorf("unexpected '%s'", strings.Join(tokens, " "))
+
+	case Yearly:
+		return r.parseYearDay(tokens)
+	}
+
+	return fmt.Errorf("modifier is not supported for the frequency")
+}
+
+// parseYearDay parses the date of yearly recurrence: "January 15", "15 January" or "the 15th of January"
+func (r *Recurrence) parseYearDay(tokens []string) error {
+	if tokens[0] == "the" {
+		tokens = tokens[1:]
+	}
+	var month, day string
+	switch {
+	case len(tokens) == 2 && isMonth(tokens[0]):
+		month, day = tokens[0], tokens[1]
+	case len(tokens) == 2 && isMonth(tokens[1]):
+		day, month = tokens[0], tokens[1]
+	case len(tokens) == 3 && tokens[1] == "of" && isMonth(tokens[2]):
+		day, month = tokens[0], tokens[2]
+	default:
+		return fmt.Errorf("unexpected '%s'", strings.Join(tokens, " "))
+	}
+
+	r.Month = monthNames[month]
+	if day == "last" {
+		r.MonthDay = -1
+		return nil
+	}
+	found := ordinalRegex.FindStringSubmatch(day)
+	if found == nil {
+		return fmt.Errorf("invalid day of month: %s", day)
+	}
+	r.MonthDay, _ = strconv.Atoi(found[1])
+	last := time.Date(2000, r.Month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if r.MonthDay == 0 || r.MonthDay > last {
+		return fmt.Errorf("invalid day of month: %d", r.MonthDay)
+	}
+	return nil
+}
+
+func isMonth(token string) bool {
+	_, ok := monthNames[token]
+	return ok
+}
+
+func isWeekday(token string) bool {
+	_, ok := weekdayNames[token]
+	return ok
+}
+
+func parseWeekdays(tokens []string) ([]time.Weekday, []string, error) {
+	var days []time.Weekday
+	expectDay := true
+	for len(tokens) != 0 {
+		token := tokens[0]
+		if expectDay {
+			day, ok := weekdayNames[token]
+			if !ok {
+				return nil, nil, fmt.Errorf("day of week expected: %s", token)
+			}
+			days = append(days, day)
+			expectDay = false
+		} else {
+			if token != "," && token != "and" {
+				break
+			}
+			expectDay = true
+		}
+		tokens = tokens[1:]
+	}
+	if expectDay {
+		return nil, nil, fmt.Errorf("day of week expected")
+	}
+	return days, tokens, nil
+}
+
+// Next returns the nearest date of the recurrence after the specified date
+func (r Recurrence) Next(date time.Time) time.Time {
+	switch r.Frequency {
+	case Daily:
+		return date.AddDate(0, 0, r.Interval)
+	case Weekly:
+		return r.nextWeekly(date)
+	case Monthly:
+		return r.nextMonthly(date)
+	case Yearly:
+		return r.nextYearly(date)
+	}
+	return date
+}
+
+func (r Recurrence) nextYearly(date time.Time) time.Time {
+	if r.Month == 0 {
+		return addMonths(date, 12*r.Interval, date.Day())
+	}
+	candidate := time.Date(date.Year(), r.Month, 1, 0, 0, 0, 0, date.Location())
+	if candidate = addMonths(candidate, 0, r.MonthDay); candidate.After(date) {
+		return candidate
+	}
+	return addMonths(candidate, 12*r.Interval, r.MonthDay)
+}
+
+func (r Recurrence) nextWeekly(date time.Time) time.Time {
+	if len(r.Weekdays) == 0 {
+		return date.AddDate(0, 0, 7*r.Interval)
+	}
+	for i := 1; i <= 7; i++ {
+		next := date.AddDate(0, 0, i)
+		if !r.hasWeekday(next.Weekday()) {
+			continue
+		}
+		if weekStart(next).Equal(weekStart(date)) {
+			return next
+		}
+		return next.AddDate(0, 0, 7*(r.Interval-1))
+	}
+	return date.AddDate(0, 0, 7*r.Interval)
+}
+
+func (r Recurrence) nextMonthly(date time.Time) time.Time {
+	switch {
+	case r.WeekdayOrdinal != 0:
+		// not every month has the 5th weekday, such months are skipped
+		for months := 0; ; months += r.Interval {
+			month := addMonths(date, months, 1)
+			candidate, ok := nthWeekday(month.Year(), month.Month(), r.Weekdays[0], r.WeekdayOrdinal, date.Location())
+			if ok && candidate.After(date) {
+				return candidate
+			}
+		}
+	case r.MonthDay != 0:
+		if candidate := addMonths(date, 0, r.MonthDay); candidate.After(date) {
+			return candidate
+		}
+		return addMonths(date, r.Interval, r.MonthDay)
+	}
+	return addMonths(date, r.Interval, date.Day())
+}
+
+func (r Recurrence) hasWeekday(day time.Weekday) bool {
+	for _, d := range r.Weekdays {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// addMonths moves the date by the months and sets the day of month clamped to the month length (-1 means the last day)
+func addMonths(date time.Time, months int, day int) time.Time {
+	first := time.Date(date.Year(), date.Month()+time.Month(months), 1, 0, 0, 0, 0, date.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	if day < 0 || day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, date.Location())
+}
+
+// nthWeekday returns the n-th weekday of the month (-1 means the last one), false if the month has no such weekday
+func nthWeekday(year int, month time.Month, day time.Weekday, n int, loc *time.Location) (time.Time, bool) {
+	if n < 0 {
+		last := time.Date(year, month+1, 0, 0, 0, 0, 0, loc)
+		offset := (int(last.Weekday()) - int(day) + 7) % 7
+		return last.AddDate(0, 0, -offset), true
+	}
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	offset := (int(day) - int(first.Weekday()) + 7) % 7
+	result := first.AddDate(0, 0, offset+7*(n-1))
+	return result, result.Month() == month
+}
+
+func weekStart(date time.Time) time.Time {
+	offset := (int(date.Weekday()) + 6) % 7
+	return time.Date(date.Year(), date.Month(), date.Day()-offset, 0, 0, 0, 0, date.Location())
+}
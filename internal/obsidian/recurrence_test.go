@@ -0,0 +1,169 @@
+package obsidian
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	tests := []struct {
+		text string
+		want Recurrence
+	}{
+		{"every day", Recurrence{Frequency: Daily, Interval: 1}},
+		{"every 3 days", Recurrence{Frequency: Daily, Interval: 3}},
+		{"every other week", Recurrence{Frequency: Weekly, Interval: 2}},
+		{"every weekday", Recurrence{Frequency: Weekly, Interval: 1, Weekdays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}}},
+		{"every Monday, Thursday", Recurrence{Frequency: Weekly, Interval: 1, Weekdays: []time.Weekday{time.Monday, time.Thursday}}},
+		{"every 2 weeks on Monday and Friday", Recurrence{Frequency: Weekly, Interval: 2, Weekdays: []time.Weekday{time.Monday, time.Friday}}},
+		{"every month on the 15th", Recurrence{Frequency: Monthly, Interval: 1, MonthDay: 15}},
+		{"every month on the last day", Recurrence{Frequency: Monthly, Interval: 1, MonthDay: -1}},
+		{"every month on the 2nd Tuesday", Recurrence{Frequency: Monthly, Interval: 1, Weekdays: []time.Weekday{time.Tuesday}, WeekdayOrdinal: 2}},
+		{"every month on the last Friday", Recurrence{Frequency: Monthly, Interval: 1, Weekdays: []time.Weekday{time.Friday}, WeekdayOrdinal: -1}},
+		{"every year", Recurrence{Frequency: Yearly, Interval: 1}},
+		{"every year on January 15", Recurrence{Frequency: Yearly, Interval: 1, Month: time.January, MonthDay: 15}},
+		{"every year on 15 March", Recurrence{Frequency: Yearly, Interval: 1, Month: time.March, MonthDay: 15}},
+		{"every year on the 1st of May", Recurrence{Frequency: Yearly, Interval: 1, Month: time.May, MonthDay: 1}},
+		{"every year on the last of February", Recurrence{Frequency: Yearly, Interval: 1, Month: time.February, MonthDay: -1}},
+		{"every week when done", Recurrence{Frequency: Weekly, Interval: 1, WhenDone: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParseRecurrence(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.want.Text = tt.text
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRecurrenceInvalid(t *testing.T) {
+	rules := []string{
+		"",
+		"every",
+		"daily",
+		"every 0 days",
+		"every fortnight",
+		"every month on the 32nd",
+		"every month on the 6th Monday",
+		"every week on",
+		"every day on Monday",
+		"every year on February 30",
+		"every Monday and",
+	}
+	for _, rule := range rules {
+		if _, err := ParseRecurrence(rule); err == nil {
+			t.Errorf("'%s' is parsed", rule)
+		}
+	}
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	tests := []struct {
+		rule string
+		from string
+		want string
+	}{
+		{"every day", "2026-10-17", "2026-10-18"},
+		{"every 3 days", "2026-10-30", "2026-11-02"},
+		{"every week", "2026-10-17", "2026-10-24"},
+		{"every weekday", "2026-10-16", "2026-10-19"},
+		{"every Monday, Thursday", "2026-10-19", "2026-10-22"},
+		{"every 2 weeks on Monday", "2026-10-19", "2026-11-02"},
+		{"every month", "2026-01-31", "2026-02-28"},
+		{"every month on the 15th", "2026-10-17", "2026-11-15"},
+		{"every month on the last day", "2026-01-31", "2026-02-28"},
+		{"every month on the 2nd Tuesday", "2026-10-17", "2026-11-10"},
+		{"every month on the last Friday", "2026-10-30", "2026-11-27"},
+		{"every month on the 5th Friday", "2026-10-30", "2027-01-29"},
+		{"every month on the 5th Friday", "2026-10-17", "2026-10-30"},
+		{"every 2 months on the 5th Monday", "2026-08-31", "2027-08-30"},
+		{"every year", "2026-10-17", "2027-10-17"},
+		{"every year on January 15", "2026-10-17", "2027-01-15"},
+		{"every year on January 15", "2026-01-10", "2026-01-15"},
+		{"every year on the last of February", "2027-03-01", "2028-02-29"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule+" from "+tt.from, func(t *testing.T) {
+			r, err := ParseRecurrence(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.Next(*date(tt.from)).Format(DateFormat); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTaskRecurrence(t *testing.T) {
+	tests := []struct {
+		line string
+		rule string
+		text string
+	}{
+		{"- [ ] water plants 🔁 every week 📅 2026-10-20", "every week", "water plants"},
+		{"- [ ] water plants 🔁 every week on Monday in the kitchen", "every week on Monday", "water plants in the kitchen"},
+		{"- [ ] birthday 🔁 every year on January 15 for Alice", "every year on January 15", "birthday for Alice"},
+		{"- [ ] report 🔁 every month when done, quickly", "every month when done", "report quickly"},
+		{"- [ ] broken 🔁 sometimes", "", "broken 🔁 sometimes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			task := ParseTask(tt.line)
+			rule := ""
+			if task.Recurrent != nil {
+				rule = task.Recurrent.Text
+			}
+			if rule != tt.rule {
+				t.Errorf("rule: got '%s', want '%s'", rule, tt.rule)
+			}
+			if task.Text != tt.text {
+				t.Errorf("text: got '%s', want '%s'", task.Text, tt.text)
+			}
+		})
+	}
+}
+
+func TestNextOccurrence(t *testing.T) {
+	task := ParseTask("- [x] pay rent 🔁 every month 🛫 2026-10-25 📅 2026-11-01 ✅ 2026-10-30 ^abc123")
+	next := task.NextOccurrence(*date("2026-10-30"))
+	want := "- [ ] pay rent 🔁 every month 🛫 2026-11-24 📅 2026-12-01"
+	if got := next.String(); got != want {
+		t.Errorf("got '%s', want '%s'", got, want)
+	}
+
+	task = ParseTask("- [x] clean 🔁 every week when done 📅 2026-10-01")
+	next = task.NextOccurrence(*date("2026-10-17"))
+	if got := next.DueDate.Format(DateFormat); got != "2026-10-24" {
+		t.Errorf("when done: got %s, want 2026-10-24", got)
+	}
+}
+
+func TestNextOccurrenceIsCopy(t *testing.T) {
+	task := ParseTask("- [x] pay rent #home 🔁 every month 🆔 rent ⛔ salary [context:: bank] 📅 2026-11-01 ✅ 2026-10-30")
+	next := task.NextOccurrence(*date("2026-10-30"))
+	want := "- [ ] pay rent #home 🔁 every month ⛔ salary [context:: bank] 📅 2026-12-01"
+	if got := next.String(); got != want {
+		t.Errorf("got '%s', want '%s'", got, want)
+	}
+
+	next.Tags[0] = "work"
+	next.DependsOn[0] = "bonus"
+	next.Fields["context"] = "online"
+	next.fieldKeys[0] = "place"
+	for i := range next.placed {
+		next.placed[i].style = styleRound
+	}
+	if got := task.String(); got != "- [x] pay rent #home 🔁 every month 🆔 rent ⛔ salary [context:: bank] 📅 2026-11-01 ✅ 2026-10-30" {
+		t.Errorf("completed task is changed: '%s'", got)
+	}
+}
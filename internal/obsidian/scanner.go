@@ -0,0 +1,183 @@
+package obsidian
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	headingRegex = regexp.MustCompile(`^ {0,3}(#{1,6})\s+(.*?)(\s+#+)?\s*$`)
+	fenceRegex   = regexp.MustCompile("^\\s*(`{3,}|~{3,})(.*)$")
+	listRegex    = regexp.MustCompile(`^\s*([*+-]|\d+[.)])\s`)
+)
+
+const (
+	frontmatterDelimiter = "---"
+	frontmatterEnd       = "..."
+	obsidianComment      = "%%"
+	htmlCommentOpen      = "<!--"
+	htmlCommentClose     = "-->"
+)
+
+// blockScanner tracks Markdown block context (frontmatter, fenced code, comments, headings) line by line
+type blockScanner struct {
+	line          int
+	inFrontmatter bool
+	fence         string
+	inComment     bool
+	inHtmlComment bool
+	headings      []string
+	parents       []*Task
+}
+
+// scanTasks calls fn for every task found in real list items of the note. Tasks inside frontmatter, fenced code blocks
+// and comments are skipped. Iteration stops when fn returns false
+func scanTasks(lines []string, fn func(idx int, t *Task) bool) {
+	s := blockScanner{}
+	for i, l := range lines {
+		if t := s.next(l); t != nil {
+			if !fn(i, t) {
+				return
+			}
+		}
+	}
+}
+
+func (s *blockScanner) next(l string) *Task {
+	s.line++
+
+	if s.line == 1 && strings.TrimRight(l, " \t") == frontmatterDelimiter {
+		s.inFrontmatter = true
+		return nil
+	}
+	if s.inFrontmatter {
+		trimmed := strings.TrimRight(l, " \t")
+		if trimmed == frontmatterDelimiter || trimmed == frontmatterEnd {
+			s.inFrontmatter = false
+		}
+		return nil
+	}
+
+	if s.fence != "" {
+		if found := fenceRegex.FindStringSubmatch(l); found != nil && isClosingFence(s.fence, found[1], found[2]) {
+			s.fence = ""
+		}
+		return nil
+	}
+
+	commented := s.inComment || s.inHtmlComment
+	s.updateComments(l)
+	if commented {
+		return nil
+	}
+
+	if found := fenceRegex.FindStringSubmatch(l); found != nil {
+		if found[1][0] != '`' || !strings.Contains(found[2], "`") {
+			s.fence = found[1]
+			return nil
+		}
+	}
+
+	if found := headingRegex.FindStringSubmatch(l); found != nil {
+		level := len(found[1])
+		if len(s.headings) >= level {
+			s.headings = s.headings[:level-1]
+		}
+		for len(s.headings) < level-1 {
+			s.headings = append(s.headings, "")
+		}
+		s.headings = append(s.headings, found[2])
+		s.parents = nil
+		return nil
+	}
+
+	t := ParseTask(l)
+	if t == nil {
+		if strings.TrimSpace(l) != "" && indentWidth(l) == 0 && !listRegex.MatchString(l) {
+			s.parents = nil
+		}
+		return nil
+	}
+	t.Line = s.line
+	t.Headings = s.headingPath()
+	s.linkParent(t)
+	return t
+}
+
+// linkParent links the task with the nearest less indented task above
+func (s *blockScanner) linkParent(t *Task) {
+	width := indentWidth(t.Indent)
+	for len(s.parents) != 0 && indentWidth(s.parents[len(s.parents)-1].Indent) >= width {
+		s.parents = s.parents[:len(s.parents)-1]
+	}
+	if len(s.parents) != 0 {
+		parent := s.parents[len(s.parents)-1]
+		t.parent = parent
+		parent.children = append(parent.children, t)
+	}
+	s.parents = append(s.parents, t)
+}
+
+func indentWidth(l string) int {
+	width := 0
+	for _, c := range l {
+		switch c {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// updateComments looks for comment delimiters in the line in order of appearance
+func (s *blockScanner) updateComments(l string) {
+	for len(l) != 0 {
+		var delim string
+		switch {
+		case s.inComment:
+			delim = obsidianComment
+		case s.inHtmlComment:
+			delim = htmlCommentClose
+		default:
+			obsIdx := strings.Index(l, obsidianComment)
+			htmlIdx := strings.Index(l, htmlCommentOpen)
+			if obsIdx < 0 && htmlIdx < 0 {
+				return
+			}
+			if htmlIdx < 0 || (obsIdx >= 0 && obsIdx < htmlIdx) {
+				s.inComment = true
+				l = l[obsIdx+len(obsidianComment):]
+			} else {
+				s.inHtmlComment = true
+				l = l[htmlIdx+len(htmlCommentOpen):]
+			}
+			continue
+		}
+
+		idx := strings.Index(l, delim)
+		if idx < 0 {
+			return
+		}
+		s.inComment = false
+		s.inHtmlComment = false
+		l = l[idx+len(delim):]
+	}
+}
+
+func (s *blockScanner) headingPath() []string {
+	var path []string
+	for _, h := range s.headings {
+		if h != "" {
+			path = append(path, h)
+		}
+	}
+	return path
+}
+
+func isClosingFence(open, fence, info string) bool {
+	return fence[0] == open[0] && len(fence) >= len(open) && strings.TrimSpace(info) == ""
+}
@@ -0,0 +1,99 @@
+package obsidian
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type scannedTask struct {
+	line     int
+	text     string
+	headings []string
+}
+
+func scanNote(note string) []scannedTask {
+	var result []scannedTask
+	scanTasks(strings.Split(note, "\n"), func(idx int, t *Task) bool {
+		result = append(result, scannedTask{line: t.Line, text: t.Text, headings: t.Headings})
+		return true
+	})
+	return result
+}
+
+func TestScanTasks(t *testing.T) {
+	tests := []struct {
+		name string
+		note string
+		want []scannedTask
+	}{
+		{
+			name: "plain list",
+			note: "* [ ] first\n- [x] second\ntext\n+ [ ] not a task bullet",
+			want: []scannedTask{{line: 1, text: "first"}, {line: 2, text: "second"}},
+		},
+		{
+			name: "frontmatter",
+			note: "---\ntags:\n- [ ] hidden\n---\n- [ ] visible",
+			want: []scannedTask{{line: 5, text: "visible"}},
+		},
+		{
+			name: "frontmatter is only at the start",
+			note: "- [ ] first\n---\n- [ ] second\n---",
+			want: []scannedTask{{line: 1, text: "first"}, {line: 3, text: "second"}},
+		},
+		{
+			name: "fenced code",
+			note: "```md\n- [ ] hidden\n```\n~~~\n- [ ] hidden\n~~~~\n- [ ] visible",
+			want: []scannedTask{{line: 7, text: "visible"}},
+		},
+		{
+			name: "fence is closed by the same fence only",
+			note: "````\n```\n- [ ] hidden\n```\n````\n- [ ] visible",
+			want: []scannedTask{{line: 6, text: "visible"}},
+		},
+		{
+			name: "inline code is not a fence",
+			note: "```code``` here\n- [ ] visible",
+			want: []scannedTask{{line: 2, text: "visible"}},
+		},
+		{
+			name: "obsidian comment",
+			note: "%%\n- [ ] hidden\n%%\n- [ ] visible %% note %%\n%% - [ ] hidden %%",
+			want: []scannedTask{{line: 4, text: "visible %% note %%"}},
+		},
+		{
+			name: "html comment",
+			note: "<!--\n- [ ] hidden\n-->\n- [ ] visible",
+			want: []scannedTask{{line: 4, text: "visible"}},
+		},
+		{
+			name: "headings",
+			note: "# Home\n- [ ] clean\n## Kitchen\n- [ ] wash\n# Work\n### Calls\n- [ ] call",
+			want: []scannedTask{
+				{line: 2, text: "clean", headings: []string{"Home"}},
+				{line: 4, text: "wash", headings: []string{"Home", "Kitchen"}},
+				{line: 7, text: "call", headings: []string{"Work", "Calls"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scanNote(tt.note); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanTasksStop(t *testing.T) {
+	count := 0
+	scanTasks([]string{"- [ ] a", "- [ ] b", "- [ ] c"}, func(idx int, t *Task) bool {
+		count++
+		return idx < 1
+	})
+	if count != 2 {
+		t.Errorf("got %d calls, want 2", count)
+	}
+}
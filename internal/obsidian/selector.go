@@ -17,7 +17,7 @@ type taskSelector func(t *Task) bool
 func getTaskSelector(s TaskSelector) taskSelector {
 	switch s {
 	case Scheduled:
-		return func(t *Task) bool { return !t.Done && t.DueDate != nil }
+		return func(t *Task) bool { return t.IsOpen() && t.ReminderDate() != nil }
 	default:
 		return func(t *Task) bool { return true }
 	}
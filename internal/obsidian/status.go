@@ -0,0 +1,118 @@
+package obsidian
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// StatusType is a semantic of the task status symbol, the same as in the Obsidian Tasks plugin
+type StatusType int
+
+const (
+	StatusTodo StatusType = iota
+	StatusInProgress
+	StatusDone
+	StatusCancelled
+	StatusNonTask
+)
+
+const (
+	StatusSymbolTodo      = ' '
+	StatusSymbolDone      = 'x'
+	StatusSymbolCancelled = '-'
+)
+
+var (
+	statusesMu sync.RWMutex
+	statuses   = defaultStatuses()
+)
+
+func defaultStatuses() map[rune]StatusType {
+	return map[rune]StatusType{
+		' ': StatusTodo,
+		'x': StatusDone,
+		'X': StatusDone,
+		'/': StatusInProgress,
+		'-': StatusCancelled,
+		// a forwarded task is rescheduled, so it is still open
+		'>': StatusTodo,
+		'?': StatusTodo,
+	}
+}
+
+func (s StatusType) String() string {
+	switch s {
+	case StatusTodo:
+		return "TODO"
+	case StatusInProgress:
+		return "IN_PROGRESS"
+	case StatusDone:
+		return "DONE"
+	case StatusCancelled:
+		return "CANCELLED"
+	default:
+		return "NON_TASK"
+	}
+}
+
+// ParseStatusType parses names of status types like "todo", "in_progress", "DONE", "cancelled", "non-task"
+func ParseStatusType(name string) (StatusType, error) {
+	normalized := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(name), "-", "_"))
+	for s := StatusTodo; s <= StatusNonTask; s++ {
+		if s.String() == normalized {
+			return s, nil
+		}
+	}
+	return StatusNonTask, fmt.Errorf("unknown status type: %s", name)
+}
+
+// RegisterStatuses adds custom task statuses to the default ones. The map key is a status symbol, the value is a name of
+// the status type
+func RegisterStatuses(custom map[string]string) error {
+	registry := defaultStatuses()
+	for symbol, typeName := range custom {
+		if utf8.RuneCountInString(symbol) != 1 {
+			return fmt.Errorf("status symbol must be a single character: '%s'", symbol)
+		}
+		statusType, err := ParseStatusType(typeName)
+		if err != nil {
+			return err
+		}
+		r, _ := utf8.DecodeRuneInString(symbol)
+		registry[r] = statusType
+	}
+
+	statusesMu.Lock()
+	statuses = registry
+	statusesMu.Unlock()
+	return nil
+}
+
+// GetStatusType returns a type of the status symbol. Unknown symbols are treated as TODO like the Tasks plugin does
+func GetStatusType(symbol rune) StatusType {
+	statusesMu.RLock()
+	defer statusesMu.RUnlock()
+
+	if s, ok := statuses[symbol]; ok {
+		return s
+	}
+	return StatusTodo
+}
+
+// StatusType returns a type of the task status
+func (t Task) StatusType() StatusType {
+	return GetStatusType(t.Status)
+}
+
+// IsDone returns true if the task is completed
+func (t Task) IsDone() bool {
+	return t.StatusType() == StatusDone
+}
+
+// IsOpen returns true if the task is not finished yet
+func (t Task) IsOpen() bool {
+	s := t.StatusType()
+	return (s == StatusTodo || s == StatusInProgress) && t.CancelledDate == nil
+}
@@ -0,0 +1,173 @@
+package obsidian
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseStatusType(t *testing.T) {
+	tests := []struct {
+		name string
+		want StatusType
+		err  bool
+	}{
+		{name: "todo", want: StatusTodo},
+		{name: "TODO", want: StatusTodo},
+		{name: "in_progress", want: StatusInProgress},
+		{name: " in-progress ", want: StatusInProgress},
+		{name: "Done", want: StatusDone},
+		{name: "cancelled", want: StatusCancelled},
+		{name: "non-task", want: StatusNonTask},
+		{name: "postponed", want: StatusNonTask, err: true},
+		{name: "", want: StatusNonTask, err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseStatusType(tt.name)
+		if (err != nil) != tt.err {
+			t.Errorf("'%s': unexpected error %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("'%s': got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterStatuses(t *testing.T) {
+	t.Cleanup(func() { _ = RegisterStatuses(nil) })
+
+	if err := RegisterStatuses(map[string]string{"!": "in_progress", "d": "cancelled", "?": "non_task"}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		symbol rune
+		want   StatusType
+	}{
+		{' ', StatusTodo},
+		{'x', StatusDone},
+		{'!', StatusInProgress},
+		{'d', StatusCancelled},
+		{'?', StatusNonTask},
+		{'*', StatusTodo},
+	}
+	for _, tt := range tests {
+		if got := GetStatusType(tt.symbol); got != tt.want {
+			t.Errorf("'%c': got %s, want %s", tt.symbol, got, tt.want)
+		}
+	}
+	if ParseTask("- [?] question") != nil {
+		t.Errorf("non-task is parsed")
+	}
+
+	invalid := []map[string]string{
+		{"ab": "done"},
+		{"": "done"},
+		{"!": "unknown"},
+	}
+	for _, custom := range invalid {
+		if err := RegisterStatuses(custom); err == nil {
+			t.Errorf("%v is registered", custom)
+		}
+	}
+	if GetStatusType('!') != StatusInProgress {
+		t.Errorf("statuses are changed by the failed registration")
+	}
+
+	if err := RegisterStatuses(nil); err != nil {
+		t.Fatal(err)
+	}
+	if GetStatusType('!') != StatusTodo || GetStatusType('?') != StatusTodo {
+		t.Errorf("custom statuses are not reset")
+	}
+}
+
+func TestSetStatus(t *testing.T) {
+	today := *date("2026-10-17")
+	tests := []struct {
+		name            string
+		line            string
+		status          rune
+		done, cancelled *time.Time
+		open            bool
+	}{
+		{name: "done", line: "- [ ] task", status: 'x', done: &today},
+		{name: "cancelled", line: "- [ ] task", status: '-', cancelled: &today},
+		{name: "in progress", line: "- [ ] task", status: '/', open: true},
+		{name: "reopen done", line: "- [x] task ✅ 2026-10-01", status: ' ', open: true},
+		{name: "reopen cancelled", line: "- [-] task ❌ 2026-10-01", status: ' ', open: true},
+		{name: "cancel done", line: "- [x] task ✅ 2026-10-01", status: '-', done: date("2026-10-01"), cancelled: &today},
+		{name: "done cancelled", line: "- [-] task ❌ 2026-10-01", status: 'x', done: &today},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := ParseTask(tt.line)
+			task.setStatus(tt.status, today)
+			if task.Status != tt.status {
+				t.Errorf("status: got '%c', want '%c'", task.Status, tt.status)
+			}
+			if !equalDates(task.DoneDate, tt.done) {
+				t.Errorf("done: got %v, want %v", task.DoneDate, tt.done)
+			}
+			if !equalDates(task.CancelledDate, tt.cancelled) {
+				t.Errorf("cancelled: got %v, want %v", task.CancelledDate, tt.cancelled)
+			}
+			if task.IsOpen() != tt.open {
+				t.Errorf("open: got %t, want %t", task.IsOpen(), tt.open)
+			}
+		})
+	}
+}
+
+func TestVaultSetTaskStatus(t *testing.T) {
+	v, dir := newTestVault(t, map[string]string{"home.md": "- [ ] paint walls ^a\n- [ ] buy paint ^b\n"})
+	if err := v.Refresh(All); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v.SetTaskStatus("a", '/'); err != nil {
+		t.Fatal(err)
+	}
+	if task, ok := v.GetTask("a"); !ok || task.StatusType() != StatusInProgress {
+		t.Errorf("task in progress is not indexed: %v", task)
+	}
+	if err := v.SetTaskStatus("b", '-'); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.GetTask("b"); ok {
+		t.Errorf("cancelled task is still indexed")
+	}
+
+	content := readTestNote(t, filepath.Join(dir, "home.md"))
+	want := "- [/] paint walls ^a\n- [-] buy paint ❌ " + dateOf(v.now()).Format(DateFormat) + " ^b\n"
+	if content != want {
+		t.Errorf("got:\n%s\nwant:\n%s", content, want)
+	}
+}
+
+func TestForwardedTask(t *testing.T) {
+	task := ParseTask("- [>] call the bank 📅 2026-10-20")
+	if task == nil {
+		t.Fatal("forwarded task is not parsed")
+	}
+	if task.StatusType() != StatusTodo || !task.IsOpen() {
+		t.Errorf("forwarded task is not open: %s", task.StatusType())
+	}
+	if got := task.String(); got != "- [>] call the bank 📅 2026-10-20" {
+		t.Errorf("unexpected line: %s", got)
+	}
+
+	v, dir := newTestVault(t, map[string]string{"home.md": "- [>] call the bank 📅 2026-10-20 ^a\n"})
+	if err := v.Refresh(Scheduled); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.GetTask("a"); !ok {
+		t.Fatal("forwarded task is not indexed")
+	}
+	if err := v.DoneTask("a"); err != nil {
+		t.Fatal(err)
+	}
+	want := "- [x] call the bank 📅 2026-10-20 ✅ " + dateOf(v.now()).Format(DateFormat) + " ^a\n"
+	if content := readTestNote(t, filepath.Join(dir, "home.md")); content != want {
+		t.Errorf("got:\n%s\nwant:\n%s", content, want)
+	}
+}
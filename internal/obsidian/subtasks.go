@@ -0,0 +1,29 @@
+package obsidian
+
+// openSubtasks returns copies of the open nested tasks keeping the hierarchy
+func openSubtasks(t *Task) []*Task {
+	var result []*Task
+	for _, child := range t.children {
+		if !child.IsOpen() {
+			continue
+		}
+		copy := *child
+		copy.parent = nil
+		copy.children = nil
+		copy.Subtasks = openSubtasks(child)
+		result = append(result, &copy)
+	}
+	return result
+}
+
+// openDescendants returns all open nested tasks of the task at any depth
+func openDescendants(t *Task) []*Task {
+	var result []*Task
+	for _, child := range t.children {
+		if child.IsOpen() {
+			result = append(result, child)
+			result = append(result, openDescendants(child)...)
+		}
+	}
+	return result
+}
@@ -0,0 +1,116 @@
+package obsidian
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScanSubtasks(t *testing.T) {
+	tests := []struct {
+		name string
+		note string
+		// want maps the task text to the text of its parent
+		want map[string]string
+	}{
+		{
+			name: "nested",
+			note: "- [ ] a\n\t- [ ] b\n\t\t- [ ] c\n\t- [ ] d\n- [ ] e",
+			want: map[string]string{"a": "", "b": "a", "c": "b", "d": "a", "e": ""},
+		},
+		{
+			name: "spaces",
+			note: "- [ ] a\n    - [ ] b\n  - [ ] c",
+			want: map[string]string{"a": "", "b": "a", "c": "a"},
+		},
+		{
+			name: "list items between",
+			note: "- [ ] a\n  - note\n  - [ ] b\n\n  continued\n  - [ ] c",
+			want: map[string]string{"a": "", "b": "a", "c": "a"},
+		},
+		{
+			name: "paragraph breaks",
+			note: "- [ ] a\nparagraph\n  - [ ] b",
+			want: map[string]string{"a": "", "b": ""},
+		},
+		{
+			name: "heading breaks",
+			note: "- [ ] a\n# Heading\n  - [ ] b",
+			want: map[string]string{"a": "", "b": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[string]string{}
+			scanTasks(strings.Split(tt.note, "\n"), func(idx int, t *Task) bool {
+				got[t.Text] = ""
+				if t.parent != nil {
+					got[t.Text] = t.parent.Text
+				}
+				return true
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenSubtasks(t *testing.T) {
+	var root *Task
+	scanTasks(strings.Split("- [ ] a\n  - [x] b\n    - [ ] c\n  - [ ] d\n    - [-] e\n    - [/] f", "\n"), func(idx int, t *Task) bool {
+		if root == nil {
+			root = t
+		}
+		return true
+	})
+
+	subtasks := openSubtasks(root)
+	if len(subtasks) != 1 || subtasks[0].Text != "d" {
+		t.Fatalf("unexpected subtasks: %v", subtasks)
+	}
+	if len(subtasks[0].Subtasks) != 1 || subtasks[0].Subtasks[0].Text != "f" {
+		t.Errorf("unexpected nested subtasks: %v", subtasks[0].Subtasks)
+	}
+
+	var descendants []string
+	for _, d := range openDescendants(root) {
+		descendants = append(descendants, d.Text)
+	}
+	if !reflect.DeepEqual(descendants, []string{"d", "f"}) {
+		t.Errorf("unexpected descendants: %v", descendants)
+	}
+}
+
+func TestDoneTaskWithSubtasks(t *testing.T) {
+	v, dir := newTestVault(t, map[string]string{
+		"home.md": "- [ ] move ^a\n  - [ ] pack ^b\n    - [ ] buy boxes ^c\n  - [x] rent a van ^d\n- [ ] relax ^e\n",
+	})
+	if err := v.Refresh(All); err != nil {
+		t.Fatal(err)
+	}
+	if task, _ := v.GetTask("a"); len(task.Subtasks) != 1 || len(task.Subtasks[0].Subtasks) != 1 {
+		t.Fatalf("unexpected subtasks: %v", task.Subtasks)
+	}
+
+	if err := v.DoneTaskWithSubtasks("a"); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		if _, ok := v.GetTask(id); ok {
+			t.Errorf("task '%s' is still indexed", id)
+		}
+	}
+	if _, ok := v.GetTask("e"); !ok {
+		t.Errorf("sibling task is not indexed")
+	}
+
+	done := " ✅ " + dateOf(v.now()).Format(DateFormat)
+	want := "- [x] move" + done + " ^a\n  - [x] pack" + done + " ^b\n    - [x] buy boxes" + done + " ^c\n" +
+		"  - [x] rent a van ^d\n- [ ] relax ^e\n"
+	if content := readTestNote(t, filepath.Join(dir, "home.md")); content != want {
+		t.Errorf("got:\n%s\nwant:\n%s", content, want)
+	}
+}
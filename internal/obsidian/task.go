@@ -7,93 +7,176 @@ import (
 	"regexp"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 const (
 	DateFormat = "2006-01-02"
+	TimeFormat = "15:04"
 )
 
 var (
-	taskStart     = regexp.MustCompile(`^\s*(\*|-) \[(x| |X)\]\s*`)
-	dueDateRegex  = regexp.MustCompile(`📅 (\d\d\d\d-\d\d-\d\d)`)
-	doneDateRegex = regexp.MustCompile(`✅ (\d\d\d\d-\d\d-\d\d)`)
+	dependencyIdRegex  = regexp.MustCompile(`🆔 ?([a-zA-Z0-9_-]+)`)
+	dependsOnRegex     = regexp.MustCompile(`⛔ ?([a-zA-Z0-9_-]+( ?, ?[a-zA-Z0-9_-]+)*)`)
+	taskStart          = regexp.MustCompile(`^(\s*)(\*|-) \[(.)\]\s*`)
+	dueDateRegex       = regexp.MustCompile(`📅 ?(\d\d\d\d-\d\d-\d\d)`)
+	doneDateRegex      = regexp.MustCompile(`✅ ?(\d\d\d\d-\d\d-\d\d)`)
+	startDateRegex     = regexp.MustCompile(`🛫 ?(\d\d\d\d-\d\d-\d\d)`)
+	scheduledDateRegex = regexp.MustCompile(`(?:⏳|⌛) ?(\d\d\d\d-\d\d-\d\d)`)
+	createdDateRegex   = regexp.MustCompile(`➕ ?(\d\d\d\d-\d\d-\d\d)`)
+	cancelledDateRegex = regexp.MustCompile(`❌ ?(\d\d\d\d-\d\d-\d\d)`)
+	timeRegex          = regexp.MustCompile(`⏰ ?(\d\d?:\d\d)`)
 )
 
 type Priority int
 
 const (
 	PriorityNo Priority = iota
+	PriorityLowest
 	PriorityLow
 	PriorityMedium
 	PriorityHigh
-)
-
-type Repetition int
-
-const (
-	RepetitionNo Repetition = iota
-	RepetitionEveryDay
-	RepetitionEveryWeek
-	RepetitionEveryMonth
-	RepetitionEveryYear
+	PriorityHighest
 )
 
 type Task struct {
 	Text      string
 	DueDate   *time.Time
 	Priority  Priority
-	Recurrent Repetition
-	Done      bool
-	DoneDate  *time.Time
+	Recurrent *Recurrence
+	// Status is a symbol of the task status, see StatusType
+	Status        rune
+	DoneDate      *time.Time
+	StartDate     *time.Time
+	ScheduledDate *time.Time
+	CreatedDate   *time.Time
+	CancelledDate *time.Time
+	// Time is an optional time of day (offset from midnight) when the user should be reminded about the task
+	Time *time.Duration
+	// BlockID is an Obsidian block id (^id) which identifies the task
+	BlockID string
+	// DependencyID is an id of the task (🆔) which other tasks could depend on
+	DependencyID string
+	// DependsOn is a list of the tasks ids (⛔) which block the task
+	DependsOn []string
+	// Blocked is set by the vault if the task depends on unfinished tasks
+	Blocked bool
+
+	// Note is a path to the note which contains the task
+	Note string
+	// Line is a number of the line in the note (starting from 1)
+	Line int
+	// Headings is a path of the headings under which the task is placed
+	Headings []string
+	// Indent is a leading whitespace of the task line, nested tasks are indented deeper than their parents
+	Indent string
+	// Subtasks contains open nested tasks, it is filled by the vault
+	Subtasks []*Task
+
+	// Tags is a list of the task tags without '#'
+	Tags []string
+	// Assignees is a list of the task assignees without '@', e.g. "@alice"
+	Assignees []string
+	// Fields contains custom Dataview inline fields of the task, e.g. [context:: home]
+	Fields map[string]string
+	// Format is a style of the fields added to the task, the fields found in the line keep their own style
+	Format FieldsFormat
+
+	fieldKeys []string
+	layout    string
+	placed    []placedField
+	bullet    string
+	// source is the line of the note which the task is parsed from
+	source   string
+	parent   *Task
+	children []*Task
+
+	// idPending is set if the block id is assigned but not written to the note yet, replacedID is the block id of the
+	// line in the note then
+	idPending  bool
+	replacedID string
 }
 
 func (p Priority) String() string {
 	switch p {
+	case PriorityLowest:
+		return "⏬"
 	case PriorityLow:
 		return "🔽"
 	case PriorityMedium:
 		return "🔼"
 	case PriorityHigh:
 		return "⏫"
+	case PriorityHighest:
+		return "🔺"
 	default:
 		return ""
 	}
 }
 
-func (r Repetition) String() string {
-	switch r {
-	case RepetitionEveryDay:
-		return "🔁 every day"
-	case RepetitionEveryWeek:
-		return "🔁 every week"
-	case RepetitionEveryMonth:
-		return "🔁 every month"
-	case RepetitionEveryYear:
-		return "🔁 every year"
+// Weight returns an order of the priority, tasks without priority are more important than low priority ones
+func (p Priority) Weight() int {
+	switch p {
+	case PriorityLowest:
+		return 0
+	case PriorityLow:
+		return 1
+	case PriorityNo:
+		return 2
 	default:
-		return ""
+		return int(p)
 	}
 }
 
 func (t Task) String() string {
-	done := " "
-	if t.Done {
-		done = "x"
+	status := t.Status
+	if status == 0 {
+		status = StatusSymbolTodo
 	}
-	result := fmt.Sprintf("* [%s] %s", done, t.Text)
-	if t.Priority != PriorityNo {
-		result += fmt.Sprintf(" %s", t.Priority)
+	bullet := t.bullet
+	if bullet == "" {
+		bullet = "*"
 	}
-	if t.Recurrent != RepetitionNo {
-		result += fmt.Sprintf(" %s", t.Recurrent)
+	result := fmt.Sprintf("%s%s [%c] %s", t.Indent, bullet, status, t.formatBody())
+	if t.BlockID != "" {
+		result += " ^" + t.BlockID
 	}
-	if t.DueDate != nil {
-		result += " 📅 " + t.DueDate.Format(DateFormat)
+	return result
+}
+
+func (t *Task) extractDate(line *string, re *regexp.Regexp, key string, dest **time.Time) {
+	found := re.FindStringSubmatch(*line)
+	if len(found) != 2 {
+		return
 	}
-	if t.DoneDate != nil {
-		result += " ✅ " + t.DoneDate.Format(DateFormat)
+	*line = strings.Replace(*line, found[0], t.place(key, styleEmoji), 1)
+	date, err := time.Parse(DateFormat, found[1])
+	if err == nil {
+		*dest = &date
 	}
-	return result
+}
+
+func (t *Task) extractTime(line *string) {
+	found := timeRegex.FindStringSubmatch(*line)
+	if len(found) != 2 {
+		return
+	}
+	*line = strings.Replace(*line, found[0], t.place("time", styleEmoji), 1)
+	if tm, err := parseTime(found[1]); err == nil {
+		t.Time = &tm
+	}
+}
+
+func parseTime(value string) (time.Duration, error) {
+	tm, err := time.Parse(TimeFormat, value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(tm.Hour())*time.Hour + time.Duration(tm.Minute())*time.Minute, nil
+}
+
+func formatTime(tm time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(tm.Hours()), int(tm.Minutes())%60)
 }
 
 func (t Task) Hash() string {
@@ -105,65 +188,172 @@ func (t Task) Hash() string {
 
 func ParseTask(line string) *Task {
 	found := taskStart.FindStringSubmatch(line)
-	if len(found) != 3 {
+	if len(found) != 4 {
 		return nil
 	}
-	t := &Task{}
-	if found[2] != " " {
-		t.Done = true
+	t := &Task{Indent: found[1], bullet: found[2], source: line}
+	t.Status, _ = utf8.DecodeRuneInString(found[3])
+	if t.StatusType() == StatusNonTask {
+		return nil
 	}
 	line = strings.Replace(line, found[0], "", 1)
+	t.BlockID = extractBlockID(&line)
+	line = t.extractInlineFields(line)
 
-	for p := PriorityLow; p <= PriorityHigh; p++ {
+	if found := dependencyIdRegex.FindStringSubmatch(line); len(found) == 2 {
+		t.DependencyID = found[1]
+		line = strings.Replace(line, found[0], t.place("id", styleEmoji), 1)
+	}
+	if found := dependsOnRegex.FindStringSubmatch(line); len(found) == 3 {
+		for _, id := range strings.Split(found[1], ",") {
+			t.DependsOn = append(t.DependsOn, strings.TrimSpace(id))
+		}
+		line = strings.Replace(line, found[0], t.place("dependsOn", styleEmoji), 1)
+	}
+
+	for p := PriorityLowest; p <= PriorityHighest; p++ {
 		if strings.Index(line, p.String()) != -1 {
 			t.Priority = p
-			line = strings.Replace(line, p.String(), "", 1)
+			line = strings.Replace(line, p.String(), t.place("priority", styleEmoji), 1)
 			break
 		}
 	}
 
-	for r := RepetitionEveryDay; r <= RepetitionEveryYear; r++ {
-		if strings.Index(line, r.String()) != -1 {
-			t.Recurrent = r
-			line = strings.Replace(line, r.String(), "", 1)
-			break
-		}
+	if r := extractRecurrence(&line, func() string { return t.place("repeat", styleEmoji) }); r != nil {
+		t.Recurrent = r
 	}
 
-	found = dueDateRegex.FindStringSubmatch(line)
-	if len(found) == 2 {
-		line = strings.Replace(line, found[0], "", 1)
-		date, err := time.Parse(DateFormat, found[1])
-		if err == nil {
-			t.DueDate = &date
-		}
+	t.extractDate(&line, dueDateRegex, "due", &t.DueDate)
+	t.extractDate(&line, doneDateRegex, "completion", &t.DoneDate)
+	t.extractDate(&line, startDateRegex, "start", &t.StartDate)
+	t.extractDate(&line, scheduledDateRegex, "scheduled", &t.ScheduledDate)
+	t.extractDate(&line, createdDateRegex, "created", &t.CreatedDate)
+	t.extractDate(&line, cancelledDateRegex, "cancelled", &t.CancelledDate)
+	t.extractTime(&line)
+	t.setLayout(line)
+	t.Tags = parseTags(t.Text)
+	t.Assignees = parseAssignees(t.Text)
+	return t
+}
+
+// Cancelled returns true if the task has the cancelled status or a cancellation date
+func (t Task) Cancelled() bool {
+	return t.StatusType() == StatusCancelled || t.CancelledDate != nil
+}
+
+// setStatus changes the task status and sets or clears the completion and cancellation dates
+func (t *Task) setStatus(status rune, date time.Time) {
+	t.Status = status
+	switch GetStatusType(status) {
+	case StatusTodo, StatusInProgress:
+		t.DoneDate = nil
+		t.CancelledDate = nil
+	case StatusDone:
+		t.DoneDate = &date
+		t.CancelledDate = nil
+	case StatusCancelled:
+		t.CancelledDate = &date
+	}
+}
+
+// ReminderDate returns the date when the user should be reminded about the task: the earlier of the scheduled and the
+// due dates, so the task scheduled after its deadline is still reminded on the due date
+func (t Task) ReminderDate() *time.Time {
+	if t.ScheduledDate != nil && (t.DueDate == nil || t.ScheduledDate.Before(*t.DueDate)) {
+		return t.ScheduledDate
+	}
+	return t.DueDate
+}
+
+// ReminderAt returns the exact time of the reminder in the location if the task has the reminder time
+func (t Task) ReminderAt(loc *time.Location) *time.Time {
+	date := t.ReminderDate()
+	if date == nil || t.Time == nil {
+		return nil
 	}
+	at := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc).Add(*t.Time)
+	return &at
+}
+
+// IsStarted returns false if the start date of the task is after the specified date
+func (t Task) IsStarted(today time.Time) bool {
+	return t.StartDate == nil || !t.StartDate.After(today)
+}
 
-	found = doneDateRegex.FindStringSubmatch(line)
-	if len(found) == 2 {
-		line = strings.Replace(line, found[0], "", 1)
-		date, err := time.Parse(DateFormat, found[1])
-		if err == nil {
-			t.DoneDate = &date
+// NextOccurrence returns the next instance of the recurrent task completed at the specified date. All task dates are
+// moved by the same offset as the reference date (due, scheduled or start date)
+func (t Task) NextOccurrence(doneDate time.Time) Task {
+	next := t
+	next.Status = StatusSymbolTodo
+	next.DoneDate = nil
+	next.CancelledDate = nil
+	next.CreatedDate = nil
+	next.BlockID = ""
+	// the id is unique, the tasks which depend on the completed one must not be blocked by the next instance
+	next.DependencyID = ""
+	next.idPending = false
+	next.replacedID = ""
+	// the next instance is a separate line without nested tasks, it must not share anything with the completed one
+	next.Tags = cloneStrings(t.Tags)
+	next.Assignees = cloneStrings(t.Assignees)
+	next.DependsOn = cloneStrings(t.DependsOn)
+	next.Headings = cloneStrings(t.Headings)
+	next.fieldKeys = cloneStrings(t.fieldKeys)
+	next.placed = append([]placedField(nil), t.placed...)
+	if t.Fields != nil {
+		next.Fields = make(map[string]string, len(t.Fields))
+		for k, v := range t.Fields {
+			next.Fields[k] = v
 		}
 	}
-	t.Text = strings.Trim(line, " ")
-	return t
+	next.Subtasks = nil
+	next.parent = nil
+	next.children = nil
+	if t.Recurrent == nil {
+		return next
+	}
+
+	base := t.DueDate
+	if base == nil {
+		base = t.ScheduledDate
+	}
+	if base == nil {
+		base = t.StartDate
+	}
+
+	from := dateOf(doneDate)
+	if !t.Recurrent.WhenDone && base != nil {
+		from = *base
+	}
+	nextDate := t.Recurrent.Next(from)
+	if base == nil {
+		next.DueDate = &nextDate
+		return next
+	}
+
+	offset := int(nextDate.Sub(*base).Hours()/24 + 0.5)
+	next.DueDate = shiftDate(t.DueDate, offset)
+	next.StartDate = shiftDate(t.StartDate, offset)
+	next.ScheduledDate = shiftDate(t.ScheduledDate, offset)
+	return next
 }
 
-func (t Task) NextDate() time.Time {
-	switch t.Recurrent {
-	case RepetitionNo:
-		return *t.DueDate
-	case RepetitionEveryDay:
-		return t.DueDate.AddDate(0, 0, 1)
-	case RepetitionEveryWeek:
-		return t.DueDate.AddDate(0, 0, 7)
-	case RepetitionEveryMonth:
-		return t.DueDate.AddDate(0, 1, 0)
-	case RepetitionEveryYear:
-		return t.DueDate.AddDate(1, 0, 0)
+func cloneStrings(s []string) []string {
+	if s == nil {
+		return nil
 	}
+	return append([]string{}, s...)
+}
+
+func shiftDate(date *time.Time, days int) *time.Time {
+	if date == nil {
+		return nil
+	}
+	shifted := date.AddDate(0, 0, days)
+	return &shifted
+}
 
-	return time.Now()
+// dateOf returns the calendar date of the time as midnight UTC, the same way as dates are parsed from notes
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
 }
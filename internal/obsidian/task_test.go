@@ -0,0 +1,143 @@
+package obsidian
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) *time.Time {
+	d, err := time.Parse(DateFormat, s)
+	if err != nil {
+		panic(err)
+	}
+	return &d
+}
+
+func equalDates(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func TestParseTaskDates(t *testing.T) {
+	tests := []struct {
+		name                                            string
+		line                                            string
+		text                                            string
+		due, scheduled, start, created, cancelled, done *time.Time
+		priority                                        Priority
+	}{
+		{
+			name: "all dates",
+			line: "- [ ] pay rent ➕ 2026-10-01 🛫 2026-10-10 ⏳ 2026-10-15 📅 2026-10-20",
+			text: "pay rent", created: date("2026-10-01"), start: date("2026-10-10"),
+			scheduled: date("2026-10-15"), due: date("2026-10-20"),
+		},
+		{
+			name: "done and cancelled",
+			line: "- [x] pay rent ✅ 2026-10-19 ❌ 2026-10-18",
+			text: "pay rent", done: date("2026-10-19"), cancelled: date("2026-10-18"),
+		},
+		{
+			name: "alternative scheduled emoji",
+			line: "- [ ] read ⌛ 2026-11-01",
+			text: "read", scheduled: date("2026-11-01"),
+		},
+		{
+			name: "no space after emoji",
+			line: "* [ ] call 📅2026-10-20",
+			text: "call", due: date("2026-10-20"),
+		},
+		{
+			name: "priority",
+			line: "- [ ] fix the roof ⏫ 📅 2026-10-20",
+			text: "fix the roof", due: date("2026-10-20"), priority: PriorityHigh,
+		},
+		{
+			name: "invalid date",
+			line: "- [ ] call 📅 2026-13-40",
+			text: "call",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := ParseTask(tt.line)
+			if task == nil {
+				t.Fatal("task is not parsed")
+			}
+			if task.Text != tt.text {
+				t.Errorf("text: got '%s', want '%s'", task.Text, tt.text)
+			}
+			if task.Priority != tt.priority {
+				t.Errorf("priority: got %d, want %d", task.Priority, tt.priority)
+			}
+			dates := []struct {
+				name      string
+				got, want *time.Time
+			}{
+				{"due", task.DueDate, tt.due},
+				{"scheduled", task.ScheduledDate, tt.scheduled},
+				{"start", task.StartDate, tt.start},
+				{"created", task.CreatedDate, tt.created},
+				{"cancelled", task.CancelledDate, tt.cancelled},
+				{"done", task.DoneDate, tt.done},
+			}
+			for _, d := range dates {
+				if !equalDates(d.got, d.want) {
+					t.Errorf("%s: got %v, want %v", d.name, d.got, d.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTaskStringRoundTrip(t *testing.T) {
+	lines := []string{
+		"- [ ] pay rent ➕ 2026-10-01 🛫 2026-10-10 ⏳ 2026-10-15 📅 2026-10-20",
+		"* [x] pay rent ✅ 2026-10-19",
+		"\t- [ ] nested ⏬ 📅 2026-10-20 ^abc123",
+		"- [ ] call ⏰ 14:30 📅 2026-10-20",
+	}
+	for _, l := range lines {
+		if got := ParseTask(l).String(); got != l {
+			t.Errorf("got '%s', want '%s'", got, l)
+		}
+	}
+}
+
+func TestReminderDate(t *testing.T) {
+	tests := []struct {
+		name           string
+		due, scheduled *time.Time
+		want           *time.Time
+	}{
+		{name: "none"},
+		{name: "due only", due: date("2026-10-20"), want: date("2026-10-20")},
+		{name: "scheduled only", scheduled: date("2026-10-15"), want: date("2026-10-15")},
+		{name: "scheduled before due", due: date("2026-10-20"), scheduled: date("2026-10-15"), want: date("2026-10-15")},
+		{name: "scheduled after due", due: date("2026-10-20"), scheduled: date("2026-10-25"), want: date("2026-10-20")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := Task{DueDate: tt.due, ScheduledDate: tt.scheduled}
+			if got := task.ReminderDate(); !equalDates(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsStarted(t *testing.T) {
+	task := Task{StartDate: date("2026-10-10")}
+	if task.IsStarted(*date("2026-10-09")) {
+		t.Error("task is started before the start date")
+	}
+	if !task.IsStarted(*date("2026-10-10")) {
+		t.Error("task is not started at the start date")
+	}
+	if !(Task{}).IsStarted(*date("2026-10-09")) {
+		t.Error("task without start date is not started")
+	}
+}
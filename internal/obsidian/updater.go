@@ -20,26 +20,120 @@ func (v *Vault) isModified(path string, modTime time.Time) bool {
 	return !note.modTime.Equal(modTime)
 }
 
+// invalidateNote makes the note be reloaded on the next update, so the index changes which are not written are reverted
+func (v *Vault) invalidateNote(path string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if note, ok := v.notes[path]; ok {
+		note.modTime = time.Time{}
+	}
+}
+
 func (v *Vault) removeNoteUnsafe(path string) {
 	note, ok := v.notes[path]
 	if !ok {
 		return
 	}
 	for _, t := range note.tasks {
-		delete(v.mapTaskToNote, t.Hash())
-		delete(v.tasks, t.Hash())
+		if v.tasks[t.ID()] == t {
+			delete(v.tasks, t.ID())
+		}
+		if t.DependencyID != "" && v.deps[t.DependencyID] == t {
+			delete(v.deps, t.DependencyID)
+		}
 	}
 	delete(v.notes, path)
 }
 
-func (v *Vault) addNoteUnsafe(path string, modTime time.Time, tasks []*Task) {
+// addNoteUnsafe indexes every selected task occurrence of the note. Tasks without block ids or with ids already taken by
+// other occurrences (e.g. a copied line) get new ones, which must be written to the note. The new ids are derived from
+// the task line, so the task keeps the same id on the next refresh if the write fails
+func (v *Vault) addNoteUnsafe(path string, modTime time.Time, tasks []*Task, blockIDs map[string]struct{}, sel taskSelector) []pendingID {
+	var pending []pendingID
+	occurrences := map[string]int{}
 	for _, t := range tasks {
-		v.mapTaskToNote[t.Hash()] = path
-		v.tasks[t.Hash()] = t
+		if t.DependencyID != "" {
+			v.deps[t.DependencyID] = t
+		}
+		if !sel(t) {
+			continue
+		}
+		if _, taken := v.tasks[t.BlockID]; t.BlockID == "" || taken {
+			hash := t.lineHash()
+			seed := path + "\x00" + t.BlockID + "\x00" + hash
+			p := pendingID{line: t.Line, hash: hash, oldId: t.BlockID, id: v.newTaskIdUnsafe(seed, occurrences[seed], blockIDs)}
+			occurrences[seed]++
+			pending = append(pending, p)
+			t.BlockID = p.id
+			t.idPending = true
+			t.replacedID = p.oldId
+		}
+		v.tasks[t.ID()] = t
 	}
 
 	n := note{modTime: modTime, tasks: tasks}
 	v.notes[path] = &n
+
+	return pending
+}
+
+// newTaskIdUnsafe derives the block id from the seed. The id must not be used by other tasks of the vault and by any
+// block of the note
+func (v *Vault) newTaskIdUnsafe(seed string, occurrence int, blockIDs map[string]struct{}) string {
+	for salt := occurrence; ; salt++ {
+		id := deriveBlockID(seed, salt)
+		if _, ok := v.tasks[id]; ok {
+			continue
+		}
+		if _, ok := blockIDs[id]; ok {
+			continue
+		}
+		blockIDs[id] = struct{}{}
+		return id
+	}
+}
+
+type pendingID struct {
+	line  int
+	hash  string
+	oldId string
+	id    string
+}
+
+func (p pendingID) matches(t *Task) bool {
+	return t.BlockID == p.oldId && t.lineHash() == p.hash
+}
+
+// writeIDs sets the assigned block ids to the task lines of the note. The line is looked up at the position where the
+// task was found, then anywhere in the note
+func (v *Vault) writeIDs(path string, ids []pendingID) error {
+	return v.modify(ErrAssignIdFailed, func() error {
+		lines, err := v.loadNote(path)
+		if err != nil {
+			return err
+		}
+
+		changed := false
+		for _, p := range ids {
+			idx := -1
+			scanTasks(lines, func(i int, t *Task) bool {
+				if p.matches(t) && (idx < 0 || t.Line == p.line) {
+					idx = i
+				}
+				return t.Line < p.line || idx < 0
+			})
+			if idx >= 0 {
+				lines[idx] = setBlockID(lines[idx], p.id)
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+
+		return v.saveNote(path, lines)
+	}, path)
 }
 
 func (v *Vault) handleUpdates() {
@@ -47,6 +141,7 @@ func (v *Vault) handleUpdates() {
 	defer v.l.Log(logger.InfoLevel, "Updating DONE")
 
 	sel := getTaskSelector(TaskSelector(v.sel.Load()))
+	defer v.notifyUpdated()
 
 	_ = v.vault.Walk(v.baseDir, func(path string, info fs.FileInfo, err error) error {
 		if err != nil {
@@ -73,7 +168,7 @@ func (v *Vault) handleUpdates() {
 			return nil
 		}
 		v.l.Logf(logger.InfoLevel, "'%s' is modified, reload", path)
-		fileTasks, err := v.extractTasks(path, sel)
+		fileTasks, blockIDs, err := v.extractTasks(path)
 		if err != nil {
 			v.l.Logf(logger.WarnLevel, "Extract tasks from '%s' failed: %s", path, err)
 			return nil
@@ -81,9 +176,15 @@ func (v *Vault) handleUpdates() {
 
 		v.mu.Lock()
 		v.removeNoteUnsafe(path)
-		v.addNoteUnsafe(path, info.ModTime(), fileTasks)
+		ids := v.addNoteUnsafe(path, info.ModTime(), fileTasks, blockIDs, sel)
 		v.mu.Unlock()
 
+		if len(ids) != 0 {
+			if err := v.writeIDs(path, ids); err != nil {
+				v.l.Logf(logger.WarnLevel, "Write block ids to '%s' failed: %s", path, err)
+			}
+		}
+
 		return nil
 	})
 }
@@ -6,22 +6,28 @@ import (
 	"strings"
 )
 
-func (v *Vault) extractTasks(fileName string, selector taskSelector) ([]*Task, error) {
+// extractTasks returns the tasks of the note and all block ids used in the note, including the ids of non-task blocks
+func (v *Vault) extractTasks(fileName string) ([]*Task, map[string]struct{}, error) {
 	var tasks []*Task
-	data, err := v.vault.Read(fileName)
+	lines, err := v.loadNote(fileName)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	f := bytes.NewReader(data)
 
-	scan := bufio.NewScanner(f)
-	for scan.Scan() {
-		if t := ParseTask(scan.Text()); t != nil && selector(t) {
-			tasks = append(tasks, t)
+	blockIDs := map[string]struct{}{}
+	for _, l := range lines {
+		if found := blockIdRegex.FindStringSubmatch(l); len(found) == 2 {
+			blockIDs[found[1]] = struct{}{}
 		}
 	}
 
-	return tasks, nil
+	scanTasks(lines, func(idx int, t *Task) bool {
+		t.Note = fileName
+		tasks = append(tasks, t)
+		return true
+	})
+
+	return tasks, blockIDs, nil
 }
 
 func escapeFileName(fn string) string {
@@ -31,24 +31,28 @@ type Vault struct {
 	errHandler DeferErrHandler
 	async      bool
 
-	mu            sync.RWMutex
-	notes         map[string]*note
-	mapTaskToNote map[string]string
-	tasks         map[string]*Task
+	loc            atomic.Pointer[time.Location]
+	unblockHandler atomic.Pointer[UnblockHandler]
+	updateHandler  atomic.Pointer[UpdateHandler]
+
+	mu    sync.RWMutex
+	notes map[string]*note
+	tasks map[string]*Task
+	deps  map[string]*Task
 }
 
 func NewVault(ctx context.Context, directory string, accessor vault.Accessor, fn DeferErrHandler, async bool) *Vault {
 	v := &Vault{
-		l:             logger.Fields(map[string]interface{}{"from": "obsidian"}),
-		vault:         accessor,
-		baseDir:       directory,
-		ctx:           ctx,
-		notes:         map[string]*note{},
-		mapTaskToNote: map[string]string{},
-		tasks:         map[string]*Task{},
-		pipeCh:        make(chan deferFn, pipelineMaxJobs),
-		errHandler:    fn,
-		async:         async,
+		l:          logger.Fields(map[string]interface{}{"from": "obsidian"}),
+		vault:      accessor,
+		baseDir:    directory,
+		ctx:        ctx,
+		notes:      map[string]*note{},
+		tasks:      map[string]*Task{},
+		deps:       map[string]*Task{},
+		pipeCh:     make(chan deferFn, pipelineMaxJobs),
+		errHandler: fn,
+		async:      async,
 	}
 
 	if async {
@@ -57,15 +61,49 @@ func NewVault(ctx context.Context, directory string, accessor vault.Accessor, fn
 	return v
 }
 
+// SetLocation sets a time zone of the vault owner. Done and cancelled dates are stamped in this zone
+func (v *Vault) SetLocation(loc *time.Location) {
+	v.loc.Store(loc)
+}
+
+func (v *Vault) now() time.Time {
+	if loc := v.loc.Load(); loc != nil {
+		return time.Now().In(loc)
+	}
+	return time.Now()
+}
+
 func (v *Vault) modify(kind ErrorKind, fn deferFn, item string) error {
+	fn = v.committed(kind, fn, item)
+	if v.ctx.Err() != nil {
+		return makeError(kind, ErrVaultClosed, item)
+	}
 	if v.async {
-		v.pipeCh <- wrapDeferFn(kind, fn, item)
-		return nil
+		select {
+		case v.pipeCh <- wrapDeferFn(kind, fn, item):
+			return nil
+		case <-v.ctx.Done():
+			return makeError(kind, ErrVaultClosed, item)
+		}
 	}
 
 	return wrapDeferFn(kind, fn, item)()
 }
 
+// committed records the changes made by the function if the accessor keeps the history
+func (v *Vault) committed(kind ErrorKind, fn deferFn, item string) deferFn {
+	c, ok := v.vault.(vault.Committer)
+	if !ok {
+		return fn
+	}
+	return func() error {
+		if err := fn(); err != nil {
+			return err
+		}
+		return c.Commit(describeChange(kind, item))
+	}
+}
+
 func (v *Vault) GetTasks() []*Task {
 	v.mu.RLock()
 	defer v.mu.RUnlock()
@@ -73,11 +111,28 @@ func (v *Vault) GetTasks() []*Task {
 	var tasks []*Task
 	for _, t := range v.tasks {
 		copy := *t
+		copy.Blocked = v.isBlockedUnsafe(t)
+		copy.Subtasks = openSubtasks(t)
 		tasks = append(tasks, &copy)
 	}
 	return tasks
 }
 
+// GetTask returns a copy of the task by the id
+func (v *Vault) GetTask(id string) (*Task, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	_, t, ok := v.lookupUnsafe(id)
+	if !ok {
+		return nil, false
+	}
+	copy := *t
+	copy.Blocked = v.isBlockedUnsafe(t)
+	copy.Subtasks = openSubtasks(t)
+	return &copy, true
+}
+
 func (v *Vault) AddNote(directory, title, content string) error {
 	fileName := pathpkg.Join(v.baseDir, directory, escapeFileName(title)+".md")
 	return v.modify(ErrAddNoteFailed, func() error {
@@ -90,7 +145,7 @@ func (v *Vault) AddTask(file string, t *Task) error {
 	return v.modify(ErrAddTaskFailed, func() error {
 		tasksFileContent, err := v.vault.Read(path)
 		if err != nil {
-			if !errors.Is(err, gowebdav.StatusError{Status: 404}) {
+			if !errors.Is(err, gowebdav.StatusError{Status: 404}) && !errors.Is(err, fs.ErrNotExist) {
 				return err
 			}
 		}
@@ -100,22 +155,44 @@ func (v *Vault) AddTask(file string, t *Task) error {
 	}, t.Text)
 }
 
+// lookupUnsafe looks for the task by the id. The legacy hash of the task line is accepted for the compatibility with
+// already sent notifications
+func (v *Vault) lookupUnsafe(id string) (string, *Task, bool) {
+	if t, ok := v.tasks[id]; ok {
+		return id, t, true
+	}
+	for taskId, t := range v.tasks {
+		if t.matchesLegacyHash(id) {
+			return taskId, t, true
+		}
+	}
+	return "", nil, false
+}
+
+// blockIDsUnsafe returns the block ids of the tasks of the note, the lines with these ids are matched by the ids only
+func (v *Vault) blockIDsUnsafe(path string) map[string]struct{} {
+	ids := map[string]struct{}{}
+	if n, ok := v.notes[path]; ok {
+		for _, t := range n.tasks {
+			if t.BlockID != "" {
+				ids[t.BlockID] = struct{}{}
+			}
+		}
+	}
+	return ids
+}
+
 func (v *Vault) SnoozeTask(id string, date time.Time) error {
 	v.mu.Lock()
-	var t *Task
-	note, ok := v.mapTaskToNote[id]
-	if ok {
-		t, ok = v.tasks[id]
-	}
+	_, t, ok := v.lookupUnsafe(id)
 	if !ok {
 		v.mu.Unlock()
 		return fmt.Errorf("task not found: %s", id)
 	}
+	target := *t
+	note := t.Note
+	known := v.blockIDsUnsafe(note)
 	t.DueDate = &date
-	delete(v.tasks, id)
-	delete(v.mapTaskToNote, id)
-	v.tasks[t.Hash()] = t
-	v.mapTaskToNote[t.Hash()] = note
 	v.mu.Unlock()
 
 	return v.modify(ErrSnoozeTaskFailed, func() error {
@@ -124,31 +201,29 @@ func (v *Vault) SnoozeTask(id string, date time.Time) error {
 			return err
 		}
 
-		for i, l := range lines {
-			if t := ParseTask(l); t != nil && t.Hash() == id {
-				t.DueDate = &date
-				lines[i] = t.String()
-				break
-			}
+		i, t := matchTask(lines, &target, known)
+		if t == nil {
+			return fmt.Errorf("cannot snooze task: %s", id)
 		}
+		t.DueDate = &date
+		t.BlockID = target.BlockID
+		lines[i] = t.String()
 
 		return v.saveNote(note, lines)
-	}, t.Text)
+	}, target.Text)
 }
 
 func (v *Vault) RemoveTask(id string) error {
 	v.mu.Lock()
-	var t *Task
-	note, ok := v.mapTaskToNote[id]
-	if ok {
-		t, ok = v.tasks[id]
-	}
+	taskId, t, ok := v.lookupUnsafe(id)
 	if !ok {
 		v.mu.Unlock()
 		return fmt.Errorf("task not found: %s", id)
 	}
-	delete(v.mapTaskToNote, id)
-	delete(v.tasks, id)
+	target := *t
+	note := t.Note
+	known := v.blockIDsUnsafe(note)
+	delete(v.tasks, taskId)
 	v.mu.Unlock()
 
 	return v.modify(ErrRemoveTaskFailed, func() error {
@@ -157,78 +232,122 @@ func (v *Vault) RemoveTask(id string) error {
 			return err
 		}
 
-		idx := -1
-		for i, l := range lines {
-			if t := ParseTask(l); t != nil && t.Hash() == id {
-				idx = i
-				break
-			}
-		}
+		idx, _ := matchTask(lines, &target, known)
 		if idx < 0 {
 			return fmt.Errorf("cannot remove task: %s", id)
 		}
 		lines = append(lines[:idx], lines[idx+1:]...)
 
 		return v.saveNote(note, lines)
-	}, t.Text)
+	}, target.Text)
 }
 
 func (v *Vault) DoneTask(id string) error {
-	v.mu.Lock()
-	var t *Task
-	note, ok := v.mapTaskToNote[id]
-	if ok {
-		t, ok = v.tasks[id]
+	return v.setTaskStatus(id, StatusSymbolDone, false)
+}
+
+// DoneTaskWithSubtasks completes the task and all its open subtasks
+func (v *Vault) DoneTaskWithSubtasks(id string) error {
+	return v.setTaskStatus(id, StatusSymbolDone, true)
+}
+
+// SetTaskStatus moves the task to the status. Completed tasks get a done date and the next occurrence if they are
+// recurrent, cancelled tasks get a cancellation date
+func (v *Vault) SetTaskStatus(id string, status rune) error {
+	return v.setTaskStatus(id, status, false)
+}
+
+func (v *Vault) setTaskStatus(id string, status rune, withSubtasks bool) error {
+	statusType := GetStatusType(status)
+	kind := ErrSetTaskStatusFailed
+	if statusType == StatusDone {
+		kind = ErrDoneTaskFailed
 	}
+
+	v.mu.Lock()
+	taskId, t, ok := v.lookupUnsafe(id)
 	if !ok {
 		v.mu.Unlock()
 		return fmt.Errorf("task not found: %s", id)
 	}
-	delete(v.mapTaskToNote, id)
-	delete(v.tasks, id)
+	target := *t
+	note := t.Note
+	known := v.blockIDsUnsafe(note)
+	var unblocked []*Task
+	if statusType == StatusTodo || statusType == StatusInProgress {
+		t.setStatus(status, dateOf(v.now()))
+	} else {
+		delete(v.tasks, taskId)
+		unblocked = v.finishUnsafe(t, status)
+	}
+	var subtasks []Task
+	if withSubtasks {
+		for _, sub := range openDescendants(t) {
+			subtasks = append(subtasks, *sub)
+			if v.tasks[sub.ID()] == sub {
+				delete(v.tasks, sub.ID())
+			}
+			unblocked = append(unblocked, v.finishUnsafe(sub, status)...)
+		}
+	}
 	v.mu.Unlock()
 
-	return v.modify(ErrDoneTaskFailed, func() error {
+	return v.modify(kind, func() (err error) {
+		// the dependent tasks are unblocked only if the status is written, otherwise the note is reloaded
+		defer func() {
+			if err != nil {
+				v.invalidateNote(note)
+			} else {
+				v.notifyUnblocked(unblocked)
+			}
+		}()
+
 		lines, err := v.loadNote(note)
 		if err != nil {
 			return err
 		}
 
-		now := time.Now()
-		for i, l := range lines {
-			if t := ParseTask(l); t != nil && t.Hash() == id {
-				t.Done = true
-				t.DoneDate = &now
+		i, t := matchTask(lines, &target, known)
+		if t == nil {
+			return fmt.Errorf("cannot change status of task: %s", id)
+		}
+
+		now := v.now()
+		t.setStatus(status, dateOf(now))
+		t.BlockID = target.BlockID
+		lines[i] = t.String()
+		if statusType == StatusDone && t.Recurrent != nil {
+			newLines := make([]string, 0, len(lines)+1)
+			next := t.NextOccurrence(now)
+			newLines = append(newLines, lines[:i]...)
+			newLines = append(newLines, next.String())
+			newLines = append(newLines, lines[i:]...)
+			lines = newLines
+		}
+
+		for _, sub := range subtasks {
+			if i, t := matchTask(lines, &sub, known); t != nil {
+				t.setStatus(status, dateOf(now))
+				t.BlockID = sub.BlockID
 				lines[i] = t.String()
-				if t.Recurrent != RepetitionNo {
-					newLines := make([]string, 0, len(lines)+1)
-					next := t.NextDate()
-					t.Done = false
-					t.DoneDate = nil
-					t.DueDate = &next
-					newLines = append(newLines, lines[:i]...)
-					newLines = append(newLines, t.String())
-					newLines = append(newLines, lines[i:]...)
-					lines = newLines
-				}
-				break
 			}
 		}
 
 		return v.saveNote(note, lines)
-	}, t.Text)
+	}, target.Text)
 }
 
-func (v *Vault) Refresh(selector TaskSelector) error {
-	v.l.Log(logger.InfoLevel, "Extracting tasks...")
-	defer v.l.Log(logger.InfoLevel, "Extracting DONE")
-
-	mapTaskToNote := make(map[string]string)
-	notes := make(map[string]*note)
-	tasks := make(map[string]*Task)
-
-	sel := getTaskSelector(selector)
+type noteInfo struct {
+	path    string
+	modTime time.Time
+	tasks   []*Task
+	// blockIDs contains all block ids of the note
+	blockIDs map[string]struct{}
+}
 
+// collectNotes extracts tasks from all notes of the vault
+func (v *Vault) collectNotes() ([]noteInfo, error) {
+	var notes []noteInfo
 	err := v.vault.Walk(v.baseDir, func(path string, info fs.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -244,17 +363,11 @@ func (v *Vault) Refresh(selector TaskSelector) error {
 		}
 
 		v.l.Logf(logger.DebugLevel, "Extracting from %s...", path)
-		fileTasks, err := v.extractTasks(path, sel)
+		fileTasks, blockIDs, err := v.extractTasks(path)
 		if err != nil {
 			v.l.Logf(logger.WarnLevel, "Extract tasks from '%s' failed: %s", path, err)
 		}
-		for _, t := range fileTasks {
-			mapTaskToNote[t.Hash()] = path
-			tasks[t.Hash()] = t
-		}
-
-		n := note{modTime: info.ModTime(), tasks: fileTasks}
-		notes[path] = &n
+		notes = append(notes, noteInfo{path: path, modTime: info.ModTime(), tasks: fileTasks, blockIDs: blockIDs})
 
 		select {
 		case <-v.ctx.Done():
@@ -265,20 +378,85 @@ func (v *Vault) Refresh(selector TaskSelector) error {
 		return nil
 	})
 
+	return notes, err
+}
+
+func (v *Vault) Refresh(selector TaskSelector) error {
+	v.l.Log(logger.InfoLevel, "Extracting tasks...")
+	defer v.l.Log(logger.InfoLevel, "Extracting DONE")
+
+	sel := getTaskSelector(selector)
+	notes, err := v.collectNotes()
 	if err != nil {
 		return err
 	}
 
+	pending := map[string][]pendingID{}
+
 	v.mu.Lock()
-	v.mapTaskToNote = mapTaskToNote
-	v.notes = notes
-	v.tasks = tasks
+	v.notes = make(map[string]*note)
+	v.tasks = make(map[string]*Task)
+	v.deps = make(map[string]*Task)
+	for _, n := range notes {
+		if ids := v.addNoteUnsafe(n.path, n.modTime, n.tasks, n.blockIDs, sel); len(ids) != 0 {
+			pending[n.path] = ids
+		}
+	}
 	v.sel.Store(uint32(selector))
 	v.mu.Unlock()
 
+	for path, ids := range pending {
+		if err := v.writeIDs(path, ids); err != nil {
+			v.l.Logf(logger.WarnLevel, "Write block ids to '%s' failed: %s", path, err)
+		}
+	}
+
+	v.notifyUpdated()
 	return nil
 }
 
+// CollectTasks reads all notes of the vault and returns the tasks matched by the filter, including the completed ones.
+// The vault index is not changed
+func (v *Vault) CollectTasks(filter func(t *Task) bool) ([]*Task, error) {
+	notes, err := v.collectNotes()
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []*Task
+	for _, n := range notes {
+		for _, t := range n.tasks {
+			if filter(t) {
+				tasks = append(tasks, t)
+			}
+		}
+	}
+	return tasks, nil
+}
+
+// NotePath returns a path of the note relative to the vault directory
+func (v *Vault) NotePath(note string) string {
+	rel, err := filepath.Rel(v.baseDir, note)
+	if err != nil {
+		return note
+	}
+	return rel
+}
+
+// UpdateHandler is called when tasks of the vault are reloaded
+type UpdateHandler func()
+
+// OnUpdated sets the handler of the vault changes
+func (v *Vault) OnUpdated(fn UpdateHandler) {
+	v.updateHandler.Store(&fn)
+}
+
+func (v *Vault) notifyUpdated() {
+	if fn := v.updateHandler.Load(); fn != nil && *fn != nil {
+		(*fn)()
+	}
+}
+
 func (v *Vault) StartWatchingChanges() {
 	w := v.vault.Watch(v.baseDir)
 	go func() {
@@ -0,0 +1,161 @@
+package obsidian
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/folder"
+)
+
+// newTestVault makes the synchronous vault over the temporary directory with the notes
+func newTestVault(t *testing.T, notes map[string]string) (*Vault, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range notes {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return NewVault(ctx, dir, folder.NewAccessor(), nil, false), dir
+}
+
+func readTestNote(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func taskIDs(tasks []*Task) []string {
+	var ids []string
+	for _, t := range tasks {
+		ids = append(ids, t.ID())
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestRefreshIndexesOccurrences(t *testing.T) {
+	v, dir := newTestVault(t, map[string]string{
+		"home.md": "# Home\n- [ ] pay rent\n- [ ] pay rent\n- [ ] copied ^dup\n- [ ] copied ^dup\n",
+	})
+	if err := v.Refresh(All); err != nil {
+		t.Fatal(err)
+	}
+
+	tasks := v.GetTasks()
+	if len(tasks) != 4 {
+		t.Fatalf("got %d tasks, want 4", len(tasks))
+	}
+	ids := taskIDs(tasks)
+	for i := 1; i < len(ids); i++ {
+		if ids[i] == ids[i-1] {
+			t.Fatalf("duplicated id: %v", ids)
+		}
+	}
+	for _, task := range tasks {
+		if task.Note != filepath.Join(dir, "home.md") {
+			t.Errorf("note: got '%s'", task.Note)
+		}
+		if len(task.Headings) != 1 || task.Headings[0] != "Home" {
+			t.Errorf("headings: got %v", task.Headings)
+		}
+		if task.Line < 2 || task.Line > 5 {
+			t.Errorf("line: got %d", task.Line)
+		}
+	}
+
+	content := readTestNote(t, filepath.Join(dir, "home.md"))
+	written := regexp.MustCompile(`\^([a-z0-9-]+)`).FindAllStringSubmatch(content, -1)
+	if len(written) != 4 || strings.Count(content, "^dup") != 1 {
+		t.Fatalf("ids are not written:\n%s", content)
+	}
+
+	if err := v.Refresh(All); err != nil {
+		t.Fatal(err)
+	}
+	if again := taskIDs(v.GetTasks()); strings.Join(again, ",") != strings.Join(ids, ",") {
+		t.Errorf("ids are changed: %v != %v", again, ids)
+	}
+}
+
+func TestDoneTaskOccurrence(t *testing.T) {
+	v, dir := newTestVault(t, map[string]string{
+		"home.md": "- [ ] pay rent ^one\n- [ ] pay rent ^two\n",
+	})
+	if err := v.Refresh(All); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.DoneTask("two"); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(readTestNote(t, filepath.Join(dir, "home.md")), "\n")
+	if lines[0] != "- [ ] pay rent ^one" {
+		t.Errorf("other occurrence is changed: '%s'", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "- [x] pay rent ✅ ") || !strings.HasSuffix(lines[1], " ^two") {
+		t.Errorf("occurrence is not done: '%s'", lines[1])
+	}
+	if _, ok := v.GetTask("two"); ok {
+		t.Errorf("done task is still indexed")
+	}
+	if _, ok := v.GetTask("one"); !ok {
+		t.Errorf("other occurrence is not indexed")
+	}
+}
+
+func TestDoneTaskByLegacyHash(t *testing.T) {
+	v, dir := newTestVault(t, map[string]string{"home.md": "- [ ] call mom ⏫ 📅 2026-10-20\n"})
+	if err := v.Refresh(All); err != nil {
+		t.Fatal(err)
+	}
+	if content := readTestNote(t, filepath.Join(dir, "home.md")); !strings.Contains(content, " ^") {
+		t.Fatalf("block id is not assigned:\n%s", content)
+	}
+	if err := v.Refresh(All); err != nil {
+		t.Fatal(err)
+	}
+
+	// the id of the button sent by the first versions
+	if err := v.DoneTask(sha1Base64("* [ ] call mom ⏫ 📅 2026-10-20")); err != nil {
+		t.Fatal(err)
+	}
+	if content := readTestNote(t, filepath.Join(dir, "home.md")); !strings.HasPrefix(content, "- [x] call mom") {
+		t.Errorf("task is not done:\n%s", content)
+	}
+}
+
+func TestDoneTaskWithRemovedBlockID(t *testing.T) {
+	v, dir := newTestVault(t, map[string]string{"home.md": "- [ ] pay rent ^a\n- [ ] pay rent for May ^b\n"})
+	if err := v.Refresh(All); err != nil {
+		t.Fatal(err)
+	}
+
+	// the user edits the line and drops the block id before the task is completed
+	path := filepath.Join(dir, "home.md")
+	if err := os.WriteFile(path, []byte("- [ ] pay rent for May ^b\n- [ ] pay the rent\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.DoneTask("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "- [ ] pay rent for May ^b\n- [x] pay the rent ✅ " + dateOf(v.now()).Format(DateFormat) + " ^a\n"
+	if content := readTestNote(t, path); content != want {
+		t.Errorf("got:\n%s\nwant:\n%s", content, want)
+	}
+}
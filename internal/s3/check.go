@@ -0,0 +1,53 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/vault"
+	"github.com/minio/minio-go/v7"
+)
+
+const checkTimeout = 15 * time.Second
+
+// Check verifies the credentials and the bucket, the vault directory must contain at least one object
+func Check(config Config, directory string) error {
+	accessor, err := NewClient(config)
+	if err != nil {
+		return fmt.Errorf("%w: %s", vault.ErrUnreachable, err)
+	}
+	c := accessor.(*Client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	exists, err := c.c.BucketExists(ctx, c.bucket)
+	if err != nil {
+		return classifyError(err)
+	}
+	if !exists {
+		return fmt.Errorf("%w: bucket %s", vault.ErrDirectoryNotFound, c.bucket)
+	}
+
+	opts := minio.ListObjectsOptions{Prefix: c.dirKey(directory), Recursive: true, MaxKeys: 1}
+	for obj := range c.c.ListObjects(ctx, c.bucket, opts) {
+		if obj.Err != nil {
+			return classifyError(obj.Err)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s", vault.ErrDirectoryNotFound, directory)
+}
+
+func classifyError(err error) error {
+	resp := minio.ToErrorResponse(err)
+	switch {
+	case resp.StatusCode == 401 || resp.StatusCode == 403:
+		return vault.ErrInvalidCredentials
+	case resp.Code == "InvalidAccessKeyId" || resp.Code == "SignatureDoesNotMatch" || resp.Code == "AccessDenied":
+		return vault.ErrInvalidCredentials
+	default:
+		return fmt.Errorf("%w: %s", vault.ErrUnreachable, err)
+	}
+}
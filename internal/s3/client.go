@@ -0,0 +1,255 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/vault"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"go-micro.dev/v4/logger"
+)
+
+// Config describes a connection to the S3 compatible storage, e.g. the bucket synced by Remotely Save
+type Config struct {
+	// Endpoint is a host of the storage, the scheme is optional and HTTPS is used by default
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+	Region    string
+	Bucket    string
+	// Prefix is prepended to the vault paths, so the vault may be kept in a subfolder of the bucket
+	Prefix string
+}
+
+type Client struct {
+	c      *minio.Client
+	bucket string
+	prefix string
+	l      logger.Logger
+}
+
+func newClient(config Config) (*minio.Client, error) {
+	endpoint := config.Endpoint
+	secure := true
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		endpoint = u.Host
+		secure = u.Scheme != "http"
+	}
+
+	return minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: secure,
+		Region: config.Region,
+	})
+}
+
+func NewClient(config Config) (vault.Accessor, error) {
+	c, err := newClient(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		c:      c,
+		bucket: config.Bucket,
+		prefix: strings.Trim(config.Prefix, "/"),
+		l:      logger.Fields(map[string]interface{}{"from": "s3"}),
+	}, nil
+}
+
+// key converts the vault path to the object key
+func (c *Client) key(path string) string {
+	return strings.TrimPrefix(pathpkg.Join(c.prefix, pathpkg.Clean("/"+path)), "/")
+}
+
+// dirKey returns the prefix of the objects placed in the directory
+func (c *Client) dirKey(path string) string {
+	key := c.key(path)
+	if key == "" {
+		return ""
+	}
+	return key + "/"
+}
+
+func (c *Client) Read(path string) ([]byte, error) {
+	obj, err := c.c.GetObject(context.Background(), c.bucket, c.key(path), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, convertError("read", path, err)
+	}
+	defer obj.Close()
+
+	content, err := io.ReadAll(obj)
+	if err != nil {
+		return nil, convertError("read", path, err)
+	}
+	return content, nil
+}
+
+func (c *Client) List(path string) ([]os.FileInfo, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	prefix := c.dirKey(path)
+	var result []os.FileInfo
+	for obj := range c.c.ListObjects(ctx, c.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if obj.Err != nil {
+			return nil, convertError("list", path, obj.Err)
+		}
+		name := strings.TrimPrefix(obj.Key, prefix)
+		if strings.HasSuffix(name, "/") {
+			result = append(result, &fileInfo{name: strings.TrimSuffix(name, "/"), dir: true})
+		} else if name != "" {
+			result = append(result, &fileInfo{name: name, size: obj.Size, modTime: obj.LastModified})
+		}
+	}
+	return result, nil
+}
+
+func (c *Client) Write(path string, content []byte) error {
+	_, err := c.c.PutObject(context.Background(), c.bucket, c.key(path), bytes.NewReader(content), int64(len(content)),
+		minio.PutObjectOptions{ContentType: "text/markdown"})
+	if err != nil {
+		return convertError("write", path, err)
+	}
+	return nil
+}
+
+// Walk lists all objects under the root by the single recursive request. S3 has no directories, so they are built
+// from the object keys
+func (c *Client) Walk(root string, fn filepath.WalkFunc) error {
+	objects, err := c.listRecursive(context.Background(), root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+
+	prefix := c.dirKey(root)
+	visited := map[string]bool{}
+	var skipped []string
+
+	isSkipped := func(rel string) bool {
+		for _, dir := range skipped {
+			if strings.HasPrefix(rel, dir+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, obj := range objects {
+		rel := strings.TrimPrefix(obj.Key, prefix)
+		if rel == "" || isSkipped(rel) {
+			continue
+		}
+
+		parts := strings.Split(strings.TrimSuffix(rel, "/"), "/")
+		dirs := parts[:len(parts)-1]
+		if strings.HasSuffix(rel, "/") {
+			// the directory marker created by the sync clients
+			dirs = parts
+		}
+
+		skip := false
+		for i := range dirs {
+			dir := strings.Join(parts[:i+1], "/")
+			if visited[dir] {
+				continue
+			}
+			visited[dir] = true
+			err = fn(pathpkg.Join(root, dir), &fileInfo{name: parts[i], dir: true}, nil)
+			if err == filepath.SkipDir {
+				skipped = append(skipped, dir)
+				skip = true
+				break
+			}
+			if err != nil {
+				return ignoreSkip(err)
+			}
+		}
+		if skip || strings.HasSuffix(rel, "/") {
+			continue
+		}
+
+		info := &fileInfo{name: parts[len(parts)-1], size: obj.Size, modTime: obj.LastModified}
+		if err = fn(pathpkg.Join(root, rel), info, nil); err != nil && err != filepath.SkipDir {
+			return ignoreSkip(err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) listRecursive(ctx context.Context, root string) ([]minio.ObjectInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var objects []minio.ObjectInfo
+	for obj := range c.c.ListObjects(ctx, c.bucket, minio.ListObjectsOptions{Prefix: c.dirKey(root), Recursive: true}) {
+		if obj.Err != nil {
+			return nil, convertError("list", root, obj.Err)
+		}
+		objects = append(objects, obj)
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func ignoreSkip(err error) error {
+	if err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// convertError maps the missing object to fs.ErrNotExist, so the callers may handle it as a missing file
+func convertError(op, path string, err error) error {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NoSuchBucket":
+		return &fs.PathError{Op: op, Path: path, Err: fs.ErrNotExist}
+	default:
+		return fmt.Errorf("%s %s failed: %w", op, path, err)
+	}
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (f *fileInfo) Name() string {
+	return f.name
+}
+
+func (f *fileInfo) Size() int64 {
+	return f.size
+}
+
+func (f *fileInfo) Mode() fs.FileMode {
+	if f.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+func (f *fileInfo) ModTime() time.Time {
+	return f.modTime
+}
+
+func (f *fileInfo) IsDir() bool {
+	return f.dir
+}
+
+func (f *fileInfo) Sys() any {
+	return nil
+}
@@ -0,0 +1,326 @@
+package s3
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/vault"
+)
+
+const (
+	testAccessKey = "access"
+	testSecretKey = "secret"
+	testBucket    = "notes"
+)
+
+type fakeObject struct {
+	content []byte
+	modTime time.Time
+}
+
+// fakeS3 is an in-process stand-in of the S3 storage with path-style addressing. It checks the access key of the
+// request, but not the signature
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]fakeObject
+}
+
+type listResult struct {
+	XMLName        xml.Name     `xml:"ListBucketResult"`
+	Name           string       `xml:"Name"`
+	Prefix         string       `xml:"Prefix"`
+	KeyCount       int          `xml:"KeyCount"`
+	MaxKeys        int          `xml:"MaxKeys"`
+	IsTruncated    bool         `xml:"IsTruncated"`
+	Contents       []listObject `xml:"Contents"`
+	CommonPrefixes []listPrefix `xml:"CommonPrefixes"`
+}
+
+type listObject struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int    `xml:"Size"`
+}
+
+type listPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+func newFakeS3(t *testing.T, objects map[string]string) string {
+	t.Helper()
+	s := &fakeS3{buckets: map[string]map[string]fakeObject{testBucket: {}}}
+	for key, content := range objects {
+		s.buckets[testBucket][key] = fakeObject{content: []byte(content), modTime: time.Now()}
+	}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func etag(content []byte) string {
+	sum := md5.Sum(content)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.Contains(r.Header.Get("Authorization"), "Credential="+testAccessKey+"/") {
+		writeError(w, http.StatusForbidden, "InvalidAccessKeyId")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucketName, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	bucket, ok := s.buckets[bucketName]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	switch {
+	case key == "" && r.URL.Query().Has("location"):
+		_, _ = w.Write([]byte("<LocationConstraint></LocationConstraint>"))
+	case key == "" && r.Method == http.MethodHead:
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, r, bucket)
+	case r.Method == http.MethodPut:
+		content, err := readPayload(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		bucket[key] = fakeObject{content: content, modTime: time.Now()}
+		w.Header().Set("ETag", etag(content))
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		obj, ok := bucket[key]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", etag(obj.content))
+		w.Header().Set("Last-Modified", obj.modTime.UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.content)))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(obj.content)
+		}
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (s *fakeS3) list(w http.ResponseWriter, r *http.Request, bucket map[string]fakeObject) {
+	query := r.URL.Query()
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	result := listResult{Name: testBucket, Prefix: prefix, MaxKeys: 1000}
+
+	keys := make([]string, 0, len(bucket))
+	for key := range bucket {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				common := key[:len(prefix)+i+1]
+				if !seen[common] {
+					seen[common] = true
+					result.CommonPrefixes = append(result.CommonPrefixes, listPrefix{Prefix: common})
+				}
+				continue
+			}
+		}
+		obj := bucket[key]
+		result.Contents = append(result.Contents, listObject{
+			Key:          key,
+			LastModified: obj.modTime.UTC().Format(time.RFC3339Nano),
+			ETag:         etag(obj.content),
+			Size:         len(obj.content),
+		})
+	}
+	result.KeyCount = len(result.Contents) + len(result.CommonPrefixes)
+
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(&result)
+}
+
+// readPayload reads the object content, the streaming uploads are sent in the aws-chunked encoding
+func readPayload(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var content []byte
+	reader := bufio.NewReader(r.Body)
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(header), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return content, nil
+		}
+		chunk := make([]byte, size+2)
+		if _, err = io.ReadFull(reader, chunk); err != nil {
+			return nil, err
+		}
+		content = append(content, chunk[:size]...)
+	}
+}
+
+func testConfig(endpoint string) Config {
+	return Config{Endpoint: endpoint, AccessKey: testAccessKey, SecretKey: testSecretKey, Bucket: testBucket}
+}
+
+func TestClient(t *testing.T) {
+	endpoint := newFakeS3(t, map[string]string{
+		"vaults/alice/tasks.md":         "- [ ] task",
+		"vaults/alice/notes/":           "",
+		"vaults/alice/notes/idea.md":    "idea",
+		"vaults/alice/.obsidian/app.md": "{}",
+		"vaults/alice/archive/old/x.md": "x",
+		"vaults/bob/tasks.md":           "- [ ] other",
+	})
+	config := testConfig(endpoint)
+	config.Prefix = "/vaults/alice/"
+	c, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := c.Read("/tasks.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "- [ ] task" {
+		t.Errorf("unexpected content: %s", content)
+	}
+	if _, err = c.Read("/missing.md"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected missing file, got %v", err)
+	}
+
+	note := strings.Repeat("long note ", 1000)
+	if err = c.Write("/notes/new.md", []byte(note)); err != nil {
+		t.Fatal(err)
+	}
+	if content, err = c.Read("notes/new.md"); err != nil || string(content) != note {
+		t.Errorf("written note is not read: %v", err)
+	}
+
+	files, err := c.List("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, fmt.Sprintf("%s:%t", f.Name(), f.IsDir()))
+	}
+	sort.Strings(names)
+	want := []string{".obsidian:true", "archive:true", "notes:true", "tasks.md:false"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+
+	var walked []string
+	err = c.Walk("/", func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".obsidian" {
+			return filepath.SkipDir
+		}
+		walked = append(walked, fmt.Sprintf("%s:%t", path, info.IsDir()))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"/archive:true", "/archive/old:true", "/archive/old/x.md:false",
+		"/notes:true", "/notes/idea.md:false", "/notes/new.md:false", "/tasks.md:false"}
+	if !reflect.DeepEqual(walked, want) {
+		t.Errorf("got %v, want %v", walked, want)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	endpoint := newFakeS3(t, map[string]string{"vault/tasks.md": "- [ ] task"})
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name      string
+		config    func(c *Config)
+		directory string
+		want      error
+	}{
+		{name: "ok", config: func(c *Config) {}, directory: "/vault"},
+		{name: "region", config: func(c *Config) { c.Region = "us-east-1" }, directory: "/vault"},
+		{name: "prefix", config: func(c *Config) { c.Prefix = "vault" }, directory: "/"},
+		{name: "wrong key", config: func(c *Config) { c.AccessKey = "wrong" }, directory: "/vault", want: vault.ErrInvalidCredentials},
+		{name: "missing bucket", config: func(c *Config) { c.Bucket = "missing" }, directory: "/vault", want: vault.ErrDirectoryNotFound},
+		{name: "empty directory", config: func(c *Config) {}, directory: "/missing", want: vault.ErrDirectoryNotFound},
+		{name: "unreachable", config: func(c *Config) { c.Endpoint = closed.URL }, directory: "/vault", want: vault.ErrUnreachable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := testConfig(endpoint)
+			tt.config(&config)
+			err := Check(config, tt.directory)
+			if tt.want == nil && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	endpoint := newFakeS3(t, map[string]string{"vault/tasks.md": "- [ ] task"})
+	c, err := NewClient(testConfig(endpoint))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := c.Watch("/vault")
+	defer w.Stop()
+
+	select {
+	case <-w.OnChanged():
+	case <-time.After(time.Second):
+		t.Fatal("initial state is not reported")
+	}
+}
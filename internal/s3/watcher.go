@@ -0,0 +1,98 @@
+package s3
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/vault"
+	"go-micro.dev/v4/logger"
+)
+
+// WatchInterval is a period of listing the bucket. It is longer than the WebDAV one since the listing is paid
+const WatchInterval = 30 * time.Second
+
+type Watcher struct {
+	c      *Client
+	path   string
+	ch     chan struct{}
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+	digest [sha256.Size]byte
+	l      logger.Logger
+}
+
+func (c *Client) Watch(path string) vault.Watcher {
+	w := &Watcher{
+		c:    c,
+		path: path,
+		ch:   make(chan struct{}),
+		l:    c.l,
+	}
+
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.process()
+	}()
+
+	return w
+}
+
+func (w *Watcher) OnChanged() <-chan struct{} {
+	return w.ch
+}
+
+func (w *Watcher) Stop() {
+	w.cancel()
+	w.wg.Wait()
+	close(w.ch)
+}
+
+func (w *Watcher) process() {
+	w.watch()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-time.After(WatchInterval):
+			w.watch()
+		}
+	}
+}
+
+// watch compares the digest of the object keys and ETags with the previous listing
+func (w *Watcher) watch() {
+	w.l.Log(logger.DebugLevel, "Retrieve changes...")
+
+	objects, err := w.c.listRecursive(w.ctx, w.path)
+	if err != nil {
+		if w.ctx.Err() == nil {
+			w.l.Logf(logger.ErrorLevel, "Retrieve changes failed: %s", err)
+		}
+		return
+	}
+	w.l.Log(logger.DebugLevel, "Retrieve DONE.")
+
+	h := sha256.New()
+	for _, obj := range objects {
+		h.Write([]byte(obj.Key))
+		h.Write([]byte{0})
+		h.Write([]byte(obj.ETag))
+		h.Write([]byte{0})
+	}
+	var digest [sha256.Size]byte
+	copy(digest[:], h.Sum(nil))
+
+	if digest != w.digest {
+		w.l.Logf(logger.InfoLevel, "Bucket changed, objects = %d", len(objects))
+		w.digest = digest
+		select {
+		case w.ch <- struct{}{}:
+		case <-w.ctx.Done():
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// encryptedPrefix marks encrypted values, the values without the prefix are stored in plaintext
+const encryptedPrefix = "enc:v1:"
+
+// Cipher encrypts secrets stored in the database with AES-GCM
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates the cipher by the base64 encoded key of 16, 24 or 32 bytes. The empty key disables encryption, nil is
+// returned in this case
+func NewCipher(key string) (*Cipher, error) {
+	if key == "" {
+		return nil, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("decode key failed: %w", err)
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// IsEncrypted returns true if the value is encrypted
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// Encrypt encrypts the value. The value is returned as is if the cipher is nil
+func (c *Cipher) Encrypt(value string) (string, error) {
+	if c == nil || value == "" {
+		return value, nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(value), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts the value. Plaintext values are returned as is
+func (c *Cipher) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if c == nil {
+		return "", errors.New("value is encrypted but encryption key is not set")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("decode value failed: %w", err)
+	}
+	size := c.aead.NonceSize()
+	if len(sealed) < size {
+		return "", errors.New("encrypted value is too short")
+	}
+	plain, err := c.aead.Open(nil, sealed[:size], sealed[size:], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt value failed: %w", err)
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,71 @@
+package secret
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// keySize is a size of the generated AES-256 key
+const keySize = 32
+
+// GenerateKey returns a new random base64 encoded key
+func GenerateKey() (string, error) {
+	raw := make([]byte, keySize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+// LoadKey reads the base64 encoded key from the file. The error wraps fs.ErrNotExist if the file does not exist
+func LoadKey(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("key file is not set")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read key failed: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// CreateKey generates a new key and saves it to the file
+func CreateKey(path string) (string, error) {
+	key, err := GenerateKey()
+	if err != nil {
+		return "", fmt.Errorf("generate key failed: %w", err)
+	}
+	if err = SaveKey(path, key); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// SaveKey writes the key to the new file, which is readable by the owner only. The existing file is not overwritten
+func SaveKey(path, key string) error {
+	if path == "" {
+		return errors.New("key file is not set")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("create key directory failed: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("create key file failed: %w", err)
+	}
+	if _, err = f.WriteString(key + "\n"); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return fmt.Errorf("write key failed: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		_ = os.Remove(path)
+		return fmt.Errorf("write key failed: %w", err)
+	}
+	return nil
+}
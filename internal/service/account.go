@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/model"
+	"github.com/RacoonMediaServer/rms-notes/internal/sftp"
+	"github.com/RacoonMediaServer/rms-notes/internal/vault"
+	rms_notes "github.com/RacoonMediaServer/rms-packages/pkg/service/rms-notes"
+	"go-micro.dev/v4/logger"
+)
+
+// checkAccess verifies the credentials of the user on the vault directory
+func (n *Notes) checkAccess(user *model.NotesUser) (rms_notes.UserLoginResponse_ErrorCode, error) {
+	directory := n.settingsOf(user.TelegramUser).Directory
+
+	err := checkBackend(backendOf(user, nil), directory)
+	if err == nil {
+		return rms_notes.UserLoginResponse_OK, nil
+	}
+
+	logger.Warnf("Check access of user %d failed: %s", user.TelegramUser, err)
+	switch {
+	case errors.Is(err, vault.ErrInvalidCredentials):
+		return rms_notes.UserLoginResponse_InvalidCredentials, nil
+	case errors.Is(err, vault.ErrDirectoryNotFound):
+		return rms_notes.UserLoginResponse_ConnectionError, fmt.Errorf("vault directory '%s' not found", directory)
+	default:
+		return rms_notes.UserLoginResponse_ConnectionError, nil
+	}
+}
+
+// Login stores the credentials of the storage backend chosen by the user, e.g. a plain WebDAV server with a bearer token.
+// UserLogin is the same for the Nextcloud backend
+func (n *Notes) Login(ctx context.Context, user *model.NotesUser) (rms_notes.UserLoginResponse_ErrorCode, error) {
+	if user.Backend == "" {
+		user.Backend = model.BackendNextcloud
+	}
+	if !isKnownBackend(user.Backend) {
+		return rms_notes.UserLoginResponse_ConnectionError, fmt.Errorf("unknown backend: %s", user.Backend)
+	}
+	user.AppPassword = false
+	if err := validateEndpoint(backendOf(user, nil)); err != nil {
+		return rms_notes.UserLoginResponse_ConnectionError, err
+	}
+	if err := pinHostKey(user); err != nil {
+		logger.Warnf("Pin host key of user %d failed: %s", user.TelegramUser, err)
+		return rms_notes.UserLoginResponse_ConnectionError, nil
+	}
+
+	code, err := n.checkAccess(user)
+	if err != nil || code != rms_notes.UserLoginResponse_OK {
+		return code, err
+	}
+	return rms_notes.UserLoginResponse_OK, n.saveCredentials(user)
+}
+
+// pinHostKey trusts the key of the SFTP server on the first login. The key is checked on every next connection
+func pinHostKey(user *model.NotesUser) error {
+	if user.Backend != model.BackendSFTP || user.HostKey != "" {
+		return nil
+	}
+	hostKey, err := sftp.ScanHostKey(user.Endpoint)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Host key of %s is pinned for user %d: %s", user.Endpoint, user.TelegramUser, hostKey)
+	user.HostKey = hostKey
+	return nil
+}
+
+// pinVaultHostKey trusts the key of the SFTP server of the vault with own endpoint when the vault is added
+func pinVaultHostKey(user *model.NotesUser, vault *model.NotesVault) error {
+	if vault.Endpoint == "" || vault.HostKey != "" || backendOf(user, vault).Backend != model.BackendSFTP {
+		return nil
+	}
+	hostKey, err := sftp.ScanHostKey(vault.Endpoint)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Host key of %s is pinned for vault '%s' of user %d: %s", vault.Endpoint, vault.Name, user.TelegramUser, hostKey)
+	vault.HostKey = hostKey
+	return nil
+}
+
+// saveCredentials stores the credentials of the user and restarts the own vaults of the user. The default vault is
+// created on the first login
+func (n *Notes) saveCredentials(user *model.NotesUser) error {
+	if err := n.db.AddUser(user); err != nil {
+		return fmt.Errorf("save user to database failed: %w", err)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if prev, ok := n.users[user.TelegramUser]; ok && prev.AppPassword && prev.Password != user.Password {
+		go n.revokeAppPassword(prev)
+	}
+	n.users[user.TelegramUser] = user
+
+	var configs []*model.NotesVault
+	for _, v := range n.vaultsOfUnsafe(user.TelegramUser) {
+		if v.user() == user.TelegramUser {
+			n.replaceVaultUnsafe(v)
+			configs = append(configs, v.cfg)
+		}
+	}
+	if len(configs) != 0 {
+		return nil
+	}
+
+	configs, err := n.ensureDefaultVaultUnsafe(user.TelegramUser, nil)
+	if err != nil {
+		return err
+	}
+	for _, cfg := range configs {
+		n.vaults[cfg.ID] = n.createVault(user, cfg, map[int32]string{})
+	}
+	return nil
+}
+
+// UpdateCredentials changes the endpoint, the login or the password of the user. Empty values are not changed
+func (n *Notes) UpdateCredentials(ctx context.Context, user int32, endpoint, login, password string) error {
+	n.mu.RLock()
+	current, ok := n.users[user]
+	n.mu.RUnlock()
+
+	if !ok {
+		return errors.New("user must login")
+	}
+
+	updated := *current
+	if password != "" {
+		updated.AppPassword = false
+	}
+	if endpoint != "" && endpoint != updated.Endpoint {
+		updated.Endpoint = endpoint
+		updated.HostKey = ""
+	}
+	if login != "" {
+		updated.Login = login
+	}
+	if password != "" {
+		updated.Password = password
+	}
+
+	if err := pinHostKey(&updated); err != nil {
+		return err
+	}
+	code, err := n.checkAccess(&updated)
+	if err != nil {
+		return err
+	}
+	switch code {
+	case rms_notes.UserLoginResponse_InvalidCredentials:
+		return errors.New("invalid credentials")
+	case rms_notes.UserLoginResponse_ConnectionError:
+		return errors.New("server is unreachable")
+	}
+
+	return n.saveCredentials(&updated)
+}
+
+// Logout stops the vaults of the user, wipes the stored credentials and revokes the app password. Vaults of other users shared with the user are
+// not affected, the user is just removed from their members
+func (n *Notes) Logout(ctx context.Context, user int32) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	u, ok := n.users[user]
+	if !ok {
+		return errors.New("user must login")
+	}
+
+	if err := n.db.RemoveUser(user); err != nil {
+		return fmt.Errorf("remove user from database failed: %w", err)
+	}
+	go n.revokeAppPassword(u)
+	if flow, ok := n.flows[user]; ok {
+		flow.cancel()
+	}
+
+	for id, v := range n.vaults {
+		if v.user() == user {
+			n.stopVaultUnsafe(v)
+			delete(n.vaults, id)
+		} else {
+			delete(v.members, user)
+		}
+	}
+	delete(n.users, user)
+
+	logger.Infof("User %d logged out", user)
+	return nil
+}
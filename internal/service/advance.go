@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/model"
+	"github.com/RacoonMediaServer/rms-notes/internal/obsidian"
+	"go-micro.dev/v4/logger"
+)
+
+const (
+	// remindBeforeField is an inline field of the task which overrides the user lead times, e.g. [remind:: 3d, 1d]
+	remindBeforeField = "remind"
+
+	// remindBeforeOff disables the advance reminders, e.g. [remind:: off]
+	remindBeforeOff = "off"
+)
+
+// defaultRemindBefore are lead times of the user who has not set them. The advance reminders are opt-in, so none
+var defaultRemindBefore []int
+
+// advanceReminder is an advance reminder which is due today
+type advanceReminder struct {
+	task     *obsidian.Task
+	daysLeft int
+	record   model.SentReminder
+}
+
+// parseRemindBefore parses a comma separated list of lead times in days, e.g. "3,1" or "1w, 2d"
+func parseRemindBefore(value string) ([]int, error) {
+	days := []int{}
+	if strings.EqualFold(strings.TrimSpace(value), remindBeforeOff) {
+		return days, nil
+	}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		multiplier := 1
+		switch {
+		case strings.HasSuffix(item, "w"):
+			multiplier = 7
+			item = strings.TrimSuffix(item, "w")
+		case strings.HasSuffix(item, "d"):
+			item = strings.TrimSuffix(item, "d")
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid lead time: %s", item)
+		}
+		days = append(days, n*multiplier)
+	}
+	sort.Ints(days)
+	return days, nil
+}
+
+func formatRemindBefore(days []int) string {
+	if len(days) == 0 {
+		return remindBeforeOff
+	}
+	items := make([]string, 0, len(days))
+	for _, d := range days {
+		items = append(items, strconv.Itoa(d))
+	}
+	return strings.Join(items, ",")
+}
+
+// GetRemindBefore returns lead times of the advance reminders of the user in days
+func (n *Notes) GetRemindBefore(ctx context.Context, user int32) ([]int, error) {
+	settings, err := n.db.LoadNotificationSettings(user)
+	if err != nil {
+		return nil, fmt.Errorf("load notification settings failed: %w", err)
+	}
+	if settings.RemindBefore == "" {
+		return append([]int{}, defaultRemindBefore...), nil
+	}
+	return parseRemindBefore(settings.RemindBefore)
+}
+
+// SetRemindBefore sets lead times of the advance reminders of the user in days, the empty list disables them
+func (n *Notes) SetRemindBefore(ctx context.Context, user int32, days []int) error {
+	for _, d := range days {
+		if d <= 0 {
+			return fmt.Errorf("invalid lead time: %d", d)
+		}
+	}
+	sorted := append([]int{}, days...)
+	sort.Ints(sorted)
+
+	settings, err := n.db.LoadNotificationSettings(user)
+	if err != nil {
+		return fmt.Errorf("load notification settings failed: %w", err)
+	}
+	settings.RemindBefore = formatRemindBefore(sorted)
+	if err = n.db.SaveNotificationSettings(settings); err != nil {
+		logger.Errorf("Save notification settings failed: %s", err)
+		return err
+	}
+	return nil
+}
+
+// notifyAboutUpcomingTasks sends advance reminders about tasks which due date is close. Each lead time is reminded once
+func (n *Notes) notifyAboutUpcomingTasks(v *userVault, user int32, tasks []*obsidian.Task) {
+	for _, r := range n.dueAdvanceReminders(user, tasks) {
+		logger.Infof("Task is upcoming: %s", r.task)
+		title := n.vaultTitle(user, v, fmt.Sprintf("Срок через %s", formatDays(r.daysLeft)))
+		n.sendTaskNotification(user, formatTask(title, r.task), r.task)
+		n.markAdvanceReminder(r)
+	}
+}
+
+// dueAdvanceReminders returns the advance reminders of the tasks which should be sent today and have not been sent yet
+func (n *Notes) dueAdvanceReminders(user int32, tasks []*obsidian.Task) []advanceReminder {
+	today := n.userToday(user)
+
+	userLeads, err := n.GetRemindBefore(context.Background(), user)
+	if err != nil {
+		logger.Warnf("Cannot get lead times of user %d: %s", user, err)
+	}
+
+	var result []advanceReminder
+	for _, t := range tasks {
+		if t.DueDate == nil || !t.IsOpen() || t.Blocked {
+			continue
+		}
+		daysLeft := int(t.DueDate.Sub(today).Hours() / 24)
+		if daysLeft <= 0 {
+			continue
+		}
+
+		leads := userLeads
+		if value, ok := t.Fields[remindBeforeField]; ok {
+			if leads, err = parseRemindBefore(value); err != nil {
+				logger.Warnf("Task '%s' has invalid lead times: %s", t.Text, err)
+				leads = userLeads
+			}
+		}
+
+		lead := -1
+		for _, l := range leads {
+			if l >= daysLeft {
+				lead = l
+				break
+			}
+		}
+		if lead < 0 {
+			continue
+		}
+
+		r := model.SentReminder{TelegramUser: user, TaskID: t.ID(), DueDate: *t.DueDate, DaysBefore: lead}
+		if sent, err := n.db.IsReminderSent(&r); err != nil || sent {
+			if err != nil {
+				logger.Errorf("Check reminder state failed: %s", err)
+			}
+			continue
+		}
+		result = append(result, advanceReminder{task: t, daysLeft: daysLeft, record: r})
+	}
+	return result
+}
+
+func (n *Notes) markAdvanceReminder(r advanceReminder) {
+	r.record.SentAt = time.Now()
+	if err := n.db.AddSentReminder(&r.record); err != nil {
+		logger.Errorf("Save reminder state failed: %s", err)
+	}
+}
+
+func formatDays(days int) string {
+	switch {
+	case days%10 == 1 && days%100 != 11:
+		return fmt.Sprintf("%d день", days)
+	case days%10 >= 2 && days%10 <= 4 && (days%100 < 12 || days%100 > 14):
+		return fmt.Sprintf("%d дня", days)
+	default:
+		return fmt.Sprintf("%d дней", days)
+	}
+}
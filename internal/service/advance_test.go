@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/config"
+	"github.com/RacoonMediaServer/rms-notes/internal/model"
+)
+
+func TestParseRemindBefore(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []int
+		err   bool
+	}{
+		{value: "3,1", want: []int{1, 3}},
+		{value: "1w, 2d", want: []int{2, 7}},
+		{value: "off", want: []int{}},
+		{value: "", want: []int{}},
+		{value: "0", err: true},
+		{value: "soon", err: true},
+	}
+	for _, tt := range tests {
+		got, err := parseRemindBefore(tt.value)
+		if (err != nil) != tt.err {
+			t.Errorf("'%s': unexpected error %v", tt.value, err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("'%s': got %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestAdvanceReminders(t *testing.T) {
+	n, db, bot := newTestNotes(t, config.Configuration{})
+	n.addTestUser(1, time.UTC)
+	n.addTestUser(2, time.UTC)
+
+	due := func(days int) string {
+		return " 📅 " + n.dateAfter(1, days) + "\n"
+	}
+	notes := map[string]string{
+		"tasks.md": "- [ ] in three days" + due(3) +
+			"- [ ] in two days" + due(2) +
+			"- [ ] tomorrow" + due(1) +
+			"- [ ] next week" + due(7) +
+			"- [ ] next week with own lead [remind:: 1w]" + due(7) +
+			"- [ ] tomorrow without reminders [remind:: off]" + due(1) +
+			"- [x] done tomorrow" + due(1) +
+			"- [ ] today" + due(0),
+	}
+	v := n.addTestVault(t, 1, 1, notes, nil)
+	other := n.addTestVault(t, 2, 2, notes, nil)
+
+	if err := n.SetRemindBefore(context.Background(), 1, []int{3, 1}); err != nil {
+		t.Fatal(err)
+	}
+	// the lead time of 3 days has been reminded already, the lead time of 1 day is not
+	tomorrow := n.userToday(1).AddDate(0, 0, 1)
+	for _, task := range v.vault.GetTasks() {
+		if task.Text == "tomorrow" {
+			_ = db.AddSentReminder(&model.SentReminder{TelegramUser: 1, TaskID: task.ID(), DueDate: tomorrow, DaysBefore: 3})
+		}
+	}
+
+	n.notifyAboutUpcomingTasks(v, 1, v.vault.GetTasks())
+	want := []string{"in three days", "in two days", "next week with own lead", "tomorrow"}
+	if got := taskTexts(bot.take(1)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	n.notifyAboutUpcomingTasks(v, 1, v.vault.GetTasks())
+	if got := bot.take(1); len(got) != 0 {
+		t.Errorf("reminders are sent again: %v", got)
+	}
+
+	// the advance reminders are opt-in
+	n.notifyAboutUpcomingTasks(other, 2, other.vault.GetTasks())
+	want = []string{"next week with own lead"}
+	if got := taskTexts(bot.take(2)); !reflect.DeepEqual(got, want) {
+		t.Errorf("user without lead times: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/git"
+	"github.com/RacoonMediaServer/rms-notes/internal/model"
+	"github.com/RacoonMediaServer/rms-notes/internal/nextcloud"
+	"github.com/RacoonMediaServer/rms-notes/internal/s3"
+	"github.com/RacoonMediaServer/rms-notes/internal/sftp"
+	"github.com/RacoonMediaServer/rms-notes/internal/vault"
+	"github.com/RacoonMediaServer/rms-notes/internal/webdav"
+)
+
+// backendConfig is a connection to the vault storage merged from the user credentials and the vault settings
+type backendConfig struct {
+	Backend  string
+	Endpoint string
+	Login    string
+	Password string
+	Token    string
+	Headers  map[string]string
+	Bucket   string
+	Prefix   string
+	Region   string
+	Branch   string
+	// PrivateKey and HostKey authenticate the SFTP and the git SSH connections
+	PrivateKey string
+	HostKey    string
+	// Clone is a path to the local clone of the git repository
+	Clone string
+	// Locked is set if the credentials cannot be decrypted, the storage is not accessed then
+	Locked bool
+}
+
+// backendOf returns the storage connection of the vault. The vault uses the user connection if it has no own endpoint.
+// The location of the vault in the storage (the S3 bucket, the prefix and the region, the git branch) falls back to the
+// user one
+func backendOf(user *model.NotesUser, cfg *model.NotesVault) backendConfig {
+	c := backendConfig{
+		Backend:  user.Backend,
+		Endpoint: user.Endpoint,
+		Login:    user.Login,
+		Password: user.Password,
+		Token:    user.Token,
+		Headers:  user.Headers,
+		Bucket:   user.Bucket,
+		Prefix:   user.Prefix,
+		Region:   user.Region,
+		Branch:   user.Branch,
+
+		PrivateKey: user.PrivateKey,
+		HostKey:    user.HostKey,
+		Locked:     user.Locked,
+	}
+	if cfg != nil {
+		if cfg.Endpoint != "" {
+			c.Endpoint = cfg.Endpoint
+			c.Login = cfg.Login
+			c.Password = cfg.Password
+			c.Token = cfg.Token
+			c.Headers = cfg.Headers
+			c.PrivateKey = cfg.PrivateKey
+			c.HostKey = cfg.HostKey
+			c.Locked = cfg.Locked
+		}
+		if cfg.Backend != "" {
+			c.Backend = cfg.Backend
+		}
+		override := func(dest *string, value string) {
+			if value != "" {
+				*dest = value
+			}
+		}
+		override(&c.Bucket, cfg.Bucket)
+		override(&c.Prefix, cfg.Prefix)
+		override(&c.Region, cfg.Region)
+		override(&c.Branch, cfg.Branch)
+	}
+	if c.Backend == "" {
+		c.Backend = model.BackendNextcloud
+	}
+	return c
+}
+
+func (c backendConfig) webDAV() webdav.Config {
+	return webdav.Config{
+		URL:      c.Endpoint,
+		User:     c.Login,
+		Password: c.Password,
+		Token:    c.Token,
+		Headers:  c.Headers,
+	}
+}
+
+func (c backendConfig) s3() s3.Config {
+	return s3.Config{
+		Endpoint:  c.Endpoint,
+		AccessKey: c.Login,
+		SecretKey: c.Password,
+		Region:    c.Region,
+		Bucket:    c.Bucket,
+		Prefix:    c.Prefix,
+	}
+}
+
+func (c backendConfig) git() git.Config {
+	return git.Config{
+		URL:       c.Endpoint,
+		Branch:    c.Branch,
+		User:      c.Login,
+		Password:  c.Password,
+		Directory: c.Clone,
+
+		PrivateKey: c.PrivateKey,
+		HostKey:    c.HostKey,
+	}
+}
+
+func (c backendConfig) sftp() sftp.Config {
+	return sftp.Config{
+		Address:    c.Endpoint,
+		User:       c.Login,
+		Password:   c.Password,
+		PrivateKey: c.PrivateKey,
+		HostKey:    c.HostKey,
+	}
+}
+
+func (c backendConfig) nextcloud() nextcloud.WebDAV {
+	return nextcloud.WebDAV{Root: c.Endpoint, User: c.Login, Password: c.Password}
+}
+
+func isKnownBackend(backend string) bool {
+	switch backend {
+	case model.BackendNextcloud, model.BackendWebDAV, model.BackendS3, model.BackendGit, model.BackendSFTP:
+		return true
+	default:
+		return false
+	}
+}
+
+// validateEndpoint rejects the endpoints which the backend must not connect to, e.g. the local git repositories
+func validateEndpoint(c backendConfig) error {
+	if c.Backend == model.BackendGit {
+		return git.ValidateURL(c.Endpoint)
+	}
+	return nil
+}
+
+// errLocked is returned for the storage whose credentials cannot be decrypted by the current key
+var errLocked = errors.New("stored credentials cannot be decrypted, login again")
+
+// newAccessor creates the accessor of the vault storage
+func newAccessor(c backendConfig) (vault.Accessor, error) {
+	if c.Locked {
+		return nil, errLocked
+	}
+	switch c.Backend {
+	case model.BackendNextcloud:
+		return nextcloud.NewClient(c.nextcloud()), nil
+	case model.BackendWebDAV:
+		return webdav.NewClient(c.webDAV()), nil
+	case model.BackendS3:
+		return s3.NewClient(c.s3())
+	case model.BackendGit:
+		return git.NewClient(c.git()), nil
+	case model.BackendSFTP:
+		return sftp.NewClient(c.sftp())
+	default:
+		return nil, fmt.Errorf("unknown backend: %s", c.Backend)
+	}
+}
+
+// checkBackend verifies the connection to the storage and the existence of the vault directory
+func checkBackend(c backendConfig, directory string) error {
+	switch c.Backend {
+	case model.BackendNextcloud:
+		return nextcloud.Check(c.nextcloud(), directory)
+	case model.BackendWebDAV:
+		return webdav.Check(c.webDAV(), directory)
+	case model.BackendS3:
+		return s3.Check(c.s3(), directory)
+	case model.BackendGit:
+		return git.Check(c.git(), directory)
+	case model.BackendSFTP:
+		return sftp.Check(c.sftp(), directory)
+	default:
+		return fmt.Errorf("unknown backend: %s", c.Backend)
+	}
+}
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"time"
+
 	"github.com/RacoonMediaServer/rms-notes/internal/model"
 	rms_notes "github.com/RacoonMediaServer/rms-packages/pkg/service/rms-notes"
 )
@@ -9,6 +11,34 @@ type Database interface {
 	LoadSettings() (*rms_notes.NotesSettings, error)
 	SaveSettings(val *rms_notes.NotesSettings) error
 
+	LoadUserSettings() (map[int32]*model.UserSettings, error)
+	GetUserSettings(user int32) (*model.UserSettings, error)
+	SaveUserSettings(settings *model.UserSettings) error
+
 	LoadUsers() (map[int32]*model.NotesUser, error)
 	AddUser(camera *model.NotesUser) error
+	RemoveUser(id int32) error
+
+	LoadVaults() ([]*model.NotesVault, error)
+	AddVault(vault *model.NotesVault) error
+	RemoveVault(id uint) error
+
+	LoadVaultMembers() ([]*model.VaultMember, error)
+	SaveVaultMember(member *model.VaultMember) error
+	RemoveVaultMember(vaultId uint, user int32) error
+
+	LoadNotificationSettings(user int32) (*model.NotificationSettings, error)
+	SaveNotificationSettings(settings *model.NotificationSettings) error
+
+	IsReminderSent(r *model.SentReminder) (bool, error)
+	AddSentReminder(r *model.SentReminder) error
+	RemoveSentReminders(before time.Time) error
+
+	LoadReminderStats(user int32, taskId string, dueDate time.Time) (*model.ReminderStats, error)
+	SaveReminderStats(stats *model.ReminderStats) error
+	RemoveReminderStats(before time.Time) error
+
+	IncSnoozeCount(user int32, taskId string, now time.Time) error
+	LoadSnoozeStats(user int32) (map[string]int, error)
+	RemoveSnoozeStats(before time.Time) error
 }
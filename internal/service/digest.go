@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/obsidian"
+	"github.com/RacoonMediaServer/rms-packages/pkg/communication"
+	"go-micro.dev/v4/logger"
+)
+
+const (
+	// agendaPageSize is a maximum number of tasks in a single agenda message
+	agendaPageSize = 10
+
+	// defaultUpcomingDays is a period of upcoming tasks in the agenda if the user has no lead times
+	defaultUpcomingDays = 7
+)
+
+type agendaItem struct {
+	task   *obsidian.Task
+	urgent bool
+	// daysLeft is set if the advance reminder about the task is due today
+	daysLeft int
+}
+
+// agenda groups tasks of the daily digest
+type agenda struct {
+	overdue  []agendaItem
+	today    []agendaItem
+	upcoming []agendaItem
+}
+
+func newAgenda() *agenda {
+	return &agenda{}
+}
+
+func (a *agenda) add(t *obsidian.Task, date time.Time, now time.Time, level escalationLevel) {
+	item := agendaItem{task: t, urgent: level == levelUrgent}
+	if date.Before(now) {
+		a.overdue = append(a.overdue, item)
+	} else {
+		a.today = append(a.today, item)
+	}
+}
+
+func (a *agenda) addUpcoming(tasks []*obsidian.Task, now time.Time, days int) {
+	until := now.AddDate(0, 0, days)
+	for _, t := range tasks {
+		date := t.ReminderDate()
+		if date == nil || !t.IsOpen() || t.Blocked || !date.After(now) || date.After(until) {
+			continue
+		}
+		a.upcoming = append(a.upcoming, agendaItem{task: t})
+	}
+}
+
+// addAdvance marks the upcoming tasks with the advance reminders, the tasks out of the upcoming period are added
+func (a *agenda) addAdvance(reminders []advanceReminder) {
+	for _, r := range reminders {
+		found := false
+		for i := range a.upcoming {
+			if a.upcoming[i].task == r.task {
+				a.upcoming[i].daysLeft = r.daysLeft
+				found = true
+			}
+		}
+		if !found {
+			a.upcoming = append(a.upcoming, agendaItem{task: r.task, daysLeft: r.daysLeft})
+		}
+	}
+}
+
+func (a *agenda) empty() bool {
+	return len(a.overdue) == 0 && len(a.today) == 0 && len(a.upcoming) == 0
+}
+
+// sortAgendaItems orders tasks by priority and then by note
+func sortAgendaItems(items []agendaItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i].task, items[j].task
+		if a.Priority.Weight() != b.Priority.Weight() {
+			return a.Priority.Weight() > b.Priority.Weight()
+		}
+		if a.Note != b.Note {
+			return a.Note < b.Note
+		}
+		return a.Line < b.Line
+	})
+}
+
+func (n *Notes) isDigestMode(user int32) bool {
+	settings, err := n.db.LoadNotificationSettings(user)
+	if err != nil {
+		logger.Warnf("Cannot load notification settings of user %d: %s", user, err)
+		return false
+	}
+	return settings.Digest
+}
+
+// GetDigestMode returns true if the user receives the daily agenda instead of a message per task
+func (n *Notes) GetDigestMode(ctx context.Context, user int32) (bool, error) {
+	settings, err := n.db.LoadNotificationSettings(user)
+	if err != nil {
+		return false, fmt.Errorf("load notification settings failed: %w", err)
+	}
+	return settings.Digest, nil
+}
+
+// SetDigestMode switches the user between the daily agenda and a message per task
+func (n *Notes) SetDigestMode(ctx context.Context, user int32, enabled bool) error {
+	settings, err := n.db.LoadNotificationSettings(user)
+	if err != nil {
+		return fmt.Errorf("load notification settings failed: %w", err)
+	}
+	settings.Digest = enabled
+	if err = n.db.SaveNotificationSettings(settings); err != nil {
+		logger.Errorf("Save notification settings failed: %s", err)
+		return err
+	}
+	return nil
+}
+
+func (n *Notes) upcomingDays(user int32) int {
+	leads, err := n.GetRemindBefore(context.Background(), user)
+	if err != nil || len(leads) == 0 {
+		return defaultUpcomingDays
+	}
+	return leads[len(leads)-1]
+}
+
+// sendAgenda sends the daily agenda split into pages. Each page has compact action buttons for its tasks
+func (n *Notes) sendAgenda(v *userVault, user int32, a *agenda) {
+	if a.empty() {
+		return
+	}
+
+	groups := []struct {
+		title string
+		items []agendaItem
+	}{
+		{"Просрочено", a.overdue},
+		{"Сегодня", a.today},
+		{"Скоро", a.upcoming},
+	}
+
+	var pages []string
+	var buttons [][]*communication.Button
+	page := ""
+	var pageButtons []*communication.Button
+	count := 0
+	number := 0
+
+	flush := func() {
+		if page != "" {
+			pages = append(pages, page)
+			buttons = append(buttons, pageButtons)
+		}
+		page = ""
+		pageButtons = nil
+		count = 0
+	}
+
+	for _, g := range groups {
+		if len(g.items) == 0 {
+			continue
+		}
+		sortAgendaItems(g.items)
+		page += fmt.Sprintf("\n<b>%s</b>\n", g.title)
+		for _, item := range g.items {
+			if count == agendaPageSize {
+				flush()
+				page = fmt.Sprintf("\n<b>%s</b>\n", g.title)
+			}
+			number++
+			count++
+			page += formatAgendaItem(number, item)
+			pageButtons = append(pageButtons, agendaButtons(number, item.task)...)
+		}
+	}
+	flush()
+
+	for i := range pages {
+		title := n.vaultTitle(user, v, "Задачи на сегодня")
+		if len(pages) > 1 {
+			title += fmt.Sprintf(" (%d/%d)", i+1, len(pages))
+		}
+		n.sendInteraction(user, "<b>"+title+"</b>\n"+pages[i], buttons[i])
+	}
+}
+
+func formatAgendaItem(number int, item agendaItem) string {
+	t := item.task
+	result := fmt.Sprintf("%d. ", number)
+	if item.urgent {
+		result += "❗"
+	}
+	if t.Priority != obsidian.PriorityNo {
+		result += t.Priority.String() + " "
+	}
+	result += t.Text
+	if date := t.ReminderDate(); date != nil {
+		result += fmt.Sprintf(" <i>(%s)</i>", date.Format(obsidian.DateFormat))
+	}
+	if item.daysLeft > 0 {
+		result += fmt.Sprintf(" 🔔 срок через %s", formatDays(item.daysLeft))
+	}
+	return result + "\n"
+}
+
+func agendaButtons(number int, t *obsidian.Task) []*communication.Button {
+	return []*communication.Button{
+		{
+			Title:   fmt.Sprintf("✅ %d", number),
+			Command: fmt.Sprintf("/tasks done %s", t.ID()),
+		},
+		{
+			Title:   fmt.Sprintf("⏰ %d", number),
+			Command: fmt.Sprintf("/tasks snooze %s", t.ID()),
+		},
+	}
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/obsidian"
+)
+
+// dispatcher keeps reminders of the tasks with exact time and gives them out when the time comes
+type dispatcher struct {
+	mu        sync.Mutex
+	reminders map[uint][]timedReminder
+}
+
+type timedReminder struct {
+	at   time.Time
+	task *obsidian.Task
+}
+
+func newDispatcher() *dispatcher {
+	return &dispatcher{reminders: map[uint][]timedReminder{}}
+}
+
+// rebuild replaces reminders of the vault by the timed tasks of today and later. The reminders of today which time has
+// passed are given out at once, the dispatcher skips ones which have been sent already
+func (d *dispatcher) rebuild(vault uint, tasks []*obsidian.Task, now time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var reminders []timedReminder
+	for _, t := range tasks {
+		at := t.ReminderAt(now.Location())
+		if at == nil || at.Before(today) || !t.IsOpen() || t.Blocked || !t.IsStarted(*t.ReminderDate()) {
+			continue
+		}
+		reminders = append(reminders, timedReminder{at: *at, task: t})
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.reminders[vault] = reminders
+}
+
+// remove drops all reminders of the vault
+func (d *dispatcher) remove(vault uint) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.reminders, vault)
+}
+
+// due returns reminders which time has come and forgets them
+func (d *dispatcher) due(now time.Time) map[uint][]timedReminder {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result := map[uint][]timedReminder{}
+	for vault, reminders := range d.reminders {
+		pending := reminders[:0]
+		for _, r := range reminders {
+			if r.at.After(now) {
+				pending = append(pending, r)
+			} else {
+				result[vault] = append(result[vault], r)
+			}
+		}
+		d.reminders[vault] = pending
+	}
+	return result
+}
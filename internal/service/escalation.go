@@ -0,0 +1,131 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/model"
+	"github.com/RacoonMediaServer/rms-notes/internal/obsidian"
+	"github.com/RacoonMediaServer/rms-packages/pkg/communication"
+	"go-micro.dev/v4/logger"
+)
+
+const (
+	defaultUrgentAfter = 3
+	defaultStaleAfter  = 7
+
+	// reminderStatsTTL is a period after which statistics of not reminded tasks are removed
+	reminderStatsTTL = 30 * 24 * time.Hour
+)
+
+type escalationLevel int
+
+const (
+	levelNormal escalationLevel = iota
+	levelUrgent
+	levelStale
+)
+
+func (n *Notes) escalationLevel(count int) escalationLevel {
+	urgentAfter := n.cfg.Escalation.UrgentAfter
+	if urgentAfter <= 0 {
+		urgentAfter = defaultUrgentAfter
+	}
+	staleAfter := n.cfg.Escalation.StaleAfter
+	if staleAfter <= 0 {
+		staleAfter = defaultStaleAfter
+	}
+
+	switch {
+	case count >= staleAfter:
+		return levelStale
+	case count >= urgentAfter:
+		return levelUrgent
+	default:
+		return levelNormal
+	}
+}
+
+// trackReminder counts the reminder about the overdue task and returns its escalation level. The task is counted once a
+// day, so the extra notifications (e.g. on the service start) do not escalate it. Stale tasks are counted too, so their
+// statistics are kept while they are reminded in the weekly digest
+func (n *Notes) trackReminder(user int32, t *obsidian.Task, date time.Time, now time.Time) (escalationLevel, int) {
+	stats, err := n.db.LoadReminderStats(user, t.ID(), date)
+	if err != nil {
+		logger.Errorf("Load reminder stats failed: %s", err)
+		stats = &model.ReminderStats{TelegramUser: user, TaskID: t.ID(), DueDate: date}
+	}
+
+	if stats.Count != 0 && !stats.LastRemindedAt.Before(now) {
+		// already counted today
+		return n.escalationLevel(stats.Count - 1), stats.Count
+	}
+
+	level := n.escalationLevel(stats.Count)
+	if stats.Count == 0 {
+		stats.FirstRemindedAt = now
+	}
+	stats.Count++
+	stats.LastRemindedAt = now
+	if err = n.db.SaveReminderStats(stats); err != nil {
+		logger.Errorf("Save reminder stats failed: %s", err)
+	}
+
+	return level, stats.Count
+}
+
+// sendEscalatedReminder sends the reminder about the overdue task in the format of the escalation level
+func (n *Notes) sendEscalatedReminder(v *userVault, user int32, t *obsidian.Task, date time.Time, now time.Time, level escalationLevel, count int) {
+	if level == levelNormal {
+		n.sendTaskNotification(user, formatTask(n.vaultTitle(user, v, "Напоминание"), t), t)
+		return
+	}
+
+	title := fmt.Sprintf("Просрочено на %s (напоминание #%d)", formatDays(overdueDays(date, now)), count)
+	n.sendInteraction(user, formatTask(n.vaultTitle(user, v, title), t), urgentTaskButtons(t, now))
+}
+
+func urgentTaskButtons(t *obsidian.Task, now time.Time) []*communication.Button {
+	nextWeek := now.AddDate(0, 0, 7).Format(obsidian.DateFormat)
+	return []*communication.Button{
+		{
+			Title:   "Отложить на неделю",
+			Command: fmt.Sprintf("/tasks snooze %s %s", t.ID(), nextWeek),
+		},
+		{
+			Title:   "Выполнить",
+			Command: fmt.Sprintf("/tasks done %s", t.ID()),
+		},
+		{
+			Title:   "Отказаться",
+			Command: fmt.Sprintf("/tasks remove %s", t.ID()),
+		},
+	}
+}
+
+func (n *Notes) isStaleDigestDay(now time.Time) bool {
+	return int(now.Weekday()) == n.cfg.Escalation.DigestWeekday
+}
+
+// notifyAboutStaleTasks sends the weekly digest of the ignored overdue tasks
+func (n *Notes) notifyAboutStaleTasks(v *userVault, user int32, tasks []*obsidian.Task) {
+	if len(tasks) == 0 {
+		return
+	}
+
+	text := "<b>" + n.vaultTitle(user, v, "Давно просроченные задачи") + "</b>\n\n"
+	for _, t := range tasks {
+		text += "• " + t.Text
+		if date := t.ReminderDate(); date != nil {
+			text += fmt.Sprintf(" (%s)", date.Format(obsidian.DateFormat))
+		}
+		text += "\n"
+	}
+	text += "\nЗадачи стоит перенести, выполнить или удалить"
+
+	n.sendMessage(user, text)
+}
+
+func overdueDays(date, now time.Time) int {
+	return int(now.Sub(date).Hours() / 24)
+}
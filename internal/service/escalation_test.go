@@ -0,0 +1,110 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/config"
+	"github.com/RacoonMediaServer/rms-notes/internal/model"
+	"github.com/RacoonMediaServer/rms-notes/internal/obsidian"
+)
+
+func TestEscalationLevel(t *testing.T) {
+	custom := &Notes{cfg: config.Configuration{Escalation: config.Escalation{UrgentAfter: 2, StaleAfter: 4}}}
+	defaults := &Notes{}
+
+	tests := []struct {
+		n     *Notes
+		count int
+		want  escalationLevel
+	}{
+		{custom, 0, levelNormal},
+		{custom, 1, levelNormal},
+		{custom, 2, levelUrgent},
+		{custom, 3, levelUrgent},
+		{custom, 4, levelStale},
+		{defaults, defaultUrgentAfter - 1, levelNormal},
+		{defaults, defaultUrgentAfter, levelUrgent},
+		{defaults, defaultStaleAfter - 1, levelUrgent},
+		{defaults, defaultStaleAfter, levelStale},
+	}
+	for _, tt := range tests {
+		if got := tt.n.escalationLevel(tt.count); got != tt.want {
+			t.Errorf("%+v, %d: got %d, want %d", tt.n.cfg.Escalation, tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestTrackReminder(t *testing.T) {
+	n, _, _ := newTestNotes(t, config.Configuration{Escalation: config.Escalation{UrgentAfter: 2, StaleAfter: 4}})
+	task := obsidian.ParseTask("- [ ] pay rent 📅 2026-10-01 ^rent")
+	due := *task.DueDate
+
+	steps := []struct {
+		day   int
+		level escalationLevel
+		count int
+	}{
+		{day: 1, level: levelNormal, count: 1},
+		{day: 2, level: levelNormal, count: 2},
+		// the extra notification of the same day does not escalate the task
+		{day: 2, level: levelNormal, count: 2},
+		{day: 3, level: levelUrgent, count: 3},
+		{day: 4, level: levelUrgent, count: 4},
+		{day: 5, level: levelStale, count: 5},
+		{day: 6, level: levelStale, count: 6},
+	}
+	for i, s := range steps {
+		level, count := n.trackReminder(1, task, due, due.AddDate(0, 0, s.day))
+		if level != s.level || count != s.count {
+			t.Errorf("step %d: got level %d and count %d, want %d and %d", i, level, count, s.level, s.count)
+		}
+	}
+}
+
+func TestEscalatedReminders(t *testing.T) {
+	n, db, bot := newTestNotes(t, config.Configuration{Escalation: config.Escalation{UrgentAfter: 2, StaleAfter: 4}})
+	n.addTestUser(1, time.UTC)
+	today := n.userToday(1)
+	n.cfg.Escalation.DigestWeekday = int(today.Weekday())
+
+	v := n.addTestVault(t, 1, 1, map[string]string{
+		"tasks.md": "- [ ] call mom 📅 " + n.dateAfter(1, -1) + " ^mom\n" +
+			"- [ ] pay rent 📅 " + n.dateAfter(1, -5) + " ^rent\n" +
+			"- [ ] fix the roof 📅 " + n.dateAfter(1, -30) + " ^roof\n",
+	}, nil)
+
+	// the reminders have been ignored for several days
+	seed := func(id string, days, count int) {
+		_ = db.SaveReminderStats(&model.ReminderStats{
+			TelegramUser:    1,
+			TaskID:          id,
+			DueDate:         today.AddDate(0, 0, -days),
+			Count:           count,
+			FirstRemindedAt: today.AddDate(0, 0, -count),
+			LastRemindedAt:  today.AddDate(0, 0, -1),
+		})
+	}
+	seed("rent", 5, 2)
+	seed("roof", 30, 4)
+
+	n.notifyUserAboutScheduledTasks(v, 1, v.vault.GetTasks())
+	messages := map[string]string{}
+	for _, m := range bot.take(1) {
+		for _, task := range []string{"call mom", "pay rent", "fix the roof"} {
+			if strings.Contains(m, task) {
+				messages[task] += m
+			}
+		}
+	}
+	if m := messages["call mom"]; !strings.HasPrefix(m, "<b>Напоминание</b>") {
+		t.Errorf("normal reminder: %s", m)
+	}
+	if m := messages["pay rent"]; !strings.HasPrefix(m, "<b>Просрочено на 5 дней (напоминание #3)</b>") || strings.Count(m, "pay rent") != 1 {
+		t.Errorf("urgent reminder: %s", m)
+	}
+	if m := messages["fix the roof"]; !strings.HasPrefix(m, "<b>Давно просроченные задачи</b>") {
+		t.Errorf("stale digest: %s", m)
+	}
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/model"
+	rms_notes_ext "github.com/RacoonMediaServer/rms-notes/pkg/rms-notes-ext"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// extHandler serves the extension API of the service
+type extHandler struct {
+	n *Notes
+}
+
+// Ext returns the handler of the extension API
+func (n *Notes) Ext() rms_notes_ext.RmsNotesExtHandler {
+	return &extHandler{n: n}
+}
+
+func (h *extHandler) SetTaskStatus(ctx context.Context, request *rms_notes_ext.SetTaskStatusRequest, empty *emptypb.Empty) error {
+	return h.n.SetTaskStatus(ctx, request.User, request.Id, request.Status)
+}
+
+func (h *extHandler) GetRemindBefore(ctx context.Context, request *rms_notes_ext.UserRequest, response *rms_notes_ext.RemindBefore) (err error) {
+	response.User = request.User
+	response.Days, err = h.n.GetRemindBefore(ctx, request.User)
+	return
+}
+
+func (h *extHandler) SetRemindBefore(ctx context.Context, request *rms_notes_ext.RemindBefore, empty *emptypb.Empty) error {
+	return h.n.SetRemindBefore(ctx, request.User, request.Days)
+}
+
+func (h *extHandler) GetDigestMode(ctx context.Context, request *rms_notes_ext.UserRequest, response *rms_notes_ext.DigestMode) (err error) {
+	response.User = request.User
+	response.Enabled, err = h.n.GetDigestMode(ctx, request.User)
+	return
+}
+
+func (h *extHandler) SetDigestMode(ctx context.Context, request *rms_notes_ext.DigestMode, empty *emptypb.Empty) error {
+	return h.n.SetDigestMode(ctx, request.User, request.Enabled)
+}
+
+func (h *extHandler) WriteReview(ctx context.Context, request *rms_notes_ext.UserRequest, empty *emptypb.Empty) error {
+	return h.n.WriteReview(ctx, request.User)
+}
+
+func (h *extHandler) GetUserSettings(ctx context.Context, request *rms_notes_ext.UserRequest, response *rms_notes_ext.UserSettings) error {
+	settings, err := h.n.GetUserSettings(ctx, request.User)
+	if err != nil {
+		return err
+	}
+	completeSubtasks := h.n.completeSubtasks(request.User)
+	*response = rms_notes_ext.UserSettings{
+		User:             request.User,
+		Directory:        &settings.Directory,
+		NotesDirectory:   &settings.NotesDirectory,
+		TasksFile:        &settings.TasksFile,
+		NotificationTime: &settings.NotificationTime,
+		TimeZone:         h.n.timeZoneOf(request.User),
+		CompleteSubtasks: &completeSubtasks,
+	}
+	return nil
+}
+
+func (h *extHandler) SetUserSettings(ctx context.Context, request *rms_notes_ext.UserSettings, empty *emptypb.Empty) error {
+	return h.n.SetUserSettings(ctx, &model.UserSettings{
+		TelegramUser:     request.User,
+		Directory:        request.Directory,
+		NotesDirectory:   request.NotesDirectory,
+		TasksFile:        request.TasksFile,
+		NotificationTime: request.NotificationTime,
+		TimeZone:         request.TimeZone,
+		CompleteSubtasks: request.CompleteSubtasks,
+	})
+}
+
+func (h *extHandler) GetVaults(ctx context.Context, request *rms_notes_ext.UserRequest, response *rms_notes_ext.Vaults) error {
+	vaults, err := h.n.GetVaults(ctx, request.User)
+	if err != nil {
+		return err
+	}
+	response.Vaults = make([]*rms_notes_ext.Vault, 0, len(vaults))
+	for _, v := range vaults {
+		response.Vaults = append(response.Vaults, vaultToExt(v))
+	}
+	return nil
+}
+
+func (h *extHandler) AddVault(ctx context.Context, request *rms_notes_ext.Vault, empty *emptypb.Empty) error {
+	return h.n.AddVault(ctx, vaultFromExt(request))
+}
+
+func (h *extHandler) RemoveVault(ctx context.Context, request *rms_notes_ext.VaultRequest, empty *emptypb.Empty) error {
+	return h.n.RemoveVault(ctx, request.User, request.Name)
+}
+
+func vaultToExt(v *model.NotesVault) *rms_notes_ext.Vault {
+	return &rms_notes_ext.Vault{
+		User:           v.TelegramUser,
+		Name:           v.Name,
+		Default:        v.Default,
+		Backend:        v.Backend,
+		Endpoint:       v.Endpoint,
+		Login:          v.Login,
+		Bucket:         v.Bucket,
+		Prefix:         v.Prefix,
+		Region:         v.Region,
+		Branch:         v.Branch,
+		HostKey:        v.HostKey,
+		Directory:      v.Directory,
+		NotesDirectory: v.NotesDirectory,
+		TasksFile:      v.TasksFile,
+	}
+}
+
+func vaultFromExt(v *rms_notes_ext.Vault) *model.NotesVault {
+	return &model.NotesVault{
+		TelegramUser:   v.User,
+		Name:           v.Name,
+		Backend:        v.Backend,
+		Endpoint:       v.Endpoint,
+		Login:          v.Login,
+		Password:       v.Password,
+		Token:          v.Token,
+		Headers:        v.Headers,
+		Bucket:         v.Bucket,
+		Prefix:         v.Prefix,
+		Region:         v.Region,
+		Branch:         v.Branch,
+		PrivateKey:     v.PrivateKey,
+		HostKey:        v.HostKey,
+		Directory:      v.Directory,
+		NotesDirectory: v.NotesDirectory,
+		TasksFile:      v.TasksFile,
+	}
+}
+
+func (h *extHandler) ShareVault(ctx context.Context, request *rms_notes_ext.ShareVaultRequest, empty *emptypb.Empty) error {
+	return h.n.ShareVault(ctx, request.User, request.Name, request.Member, request.Alias)
+}
+
+func (h *extHandler) UnshareVault(ctx context.Context, request *rms_notes_ext.ShareVaultRequest, empty *emptypb.Empty) error {
+	return h.n.UnshareVault(ctx, request.User, request.Name, request.Member)
+}
+
+func (h *extHandler) Logout(ctx context.Context, request *rms_notes_ext.UserRequest, empty *emptypb.Empty) error {
+	return h.n.Logout(ctx, request.User)
+}
+
+func (h *extHandler) UpdateCredentials(ctx context.Context, request *rms_notes_ext.UpdateCredentialsRequest, empty *emptypb.Empty) error {
+	return h.n.UpdateCredentials(ctx, request.User, request.Endpoint, request.Login, request.Password)
+}
+
+func (h *extHandler) StartLoginFlow(ctx context.Context, request *rms_notes_ext.StartLoginFlowRequest, empty *emptypb.Empty) error {
+	return h.n.StartLoginFlow(ctx, request.User, request.Server)
+}
+
+func (h *extHandler) Login(ctx context.Context, request *rms_notes_ext.LoginRequest, response *rms_notes_ext.LoginResponse) (err error) {
+	user := model.NotesUser{
+		TelegramUser: request.User,
+		Backend:      request.Backend,
+		Endpoint:     request.Endpoint,
+		Login:        request.Login,
+		Password:     request.Password,
+		Token:        request.Token,
+		Headers:      request.Headers,
+		Bucket:       request.Bucket,
+		Prefix:       request.Prefix,
+		Region:       request.Region,
+		Branch:       request.Branch,
+		PrivateKey:   request.PrivateKey,
+		HostKey:      request.HostKey,
+	}
+	response.Code, err = h.n.Login(ctx, &user)
+	return
+}
@@ -2,18 +2,43 @@ package service
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/RacoonMediaServer/rms-notes/internal/obsidian"
 )
 
-func formatTask(t *obsidian.Task) string {
-	result := "<b>Напоминание</b>\n\n"
+func formatTask(title string, t *obsidian.Task) string {
+	result := fmt.Sprintf("<b>%s</b>\n\n", title)
 	result += fmt.Sprintf("<b>Задача:</b> %s\n", t.Text)
+	if len(t.Headings) != 0 {
+		result += fmt.Sprintf("<b>Раздел:</b> %s\n", strings.Join(t.Headings, " / "))
+	}
+	if t.StatusType() == obsidian.StatusInProgress {
+		result += "<b>Статус:</b> в работе\n"
+	}
 	if t.Priority != obsidian.PriorityNo {
 		result += fmt.Sprintf("<b>Приоритет:</b> %s\n", t.Priority)
 	}
-	if t.Recurrent != obsidian.RepetitionNo {
-		result += fmt.Sprintf("<b>Повторение:</b> %s\n", t.Recurrent)
+	if t.DueDate != nil {
+		result += fmt.Sprintf("<b>Срок:</b> %s\n", t.DueDate.Format(obsidian.DateFormat))
+	}
+	if t.ScheduledDate != nil {
+		result += fmt.Sprintf("<b>Запланировано:</b> %s\n", t.ScheduledDate.Format(obsidian.DateFormat))
+	}
+	if t.Recurrent != nil {
+		result += fmt.Sprintf("<b>Повторение:</b> %s\n", t.Recurrent.Text)
+	}
+	if len(t.Subtasks) != 0 {
+		result += "<b>Подзадачи:</b>\n" + formatSubtasks(t.Subtasks, 0)
+	}
+	return result
+}
+
+func formatSubtasks(subtasks []*obsidian.Task, level int) string {
+	result := ""
+	for _, t := range subtasks {
+		result += fmt.Sprintf("%s• %s\n", strings.Repeat("  ", level), t.Text)
+		result += formatSubtasks(t.Subtasks, level+1)
 	}
 	return result
 }
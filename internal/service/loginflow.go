@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/model"
+	"github.com/RacoonMediaServer/rms-notes/internal/nextcloud"
+	"github.com/RacoonMediaServer/rms-notes/internal/vault"
+	rms_notes "github.com/RacoonMediaServer/rms-packages/pkg/service/rms-notes"
+	"go-micro.dev/v4/logger"
+)
+
+const loginFlowPollInterval = 5 * time.Second
+
+// pendingFlow is a login flow waiting for the user
+type pendingFlow struct {
+	cancel context.CancelFunc
+}
+
+// StartLoginFlow starts the Nextcloud Login Flow v2 for the user. The login URL is sent to the user by the bot, the
+// issued app password is stored when the user grants access
+func (n *Notes) StartLoginFlow(ctx context.Context, user int32, server string) error {
+	flow, err := nextcloud.StartLoginFlow(ctx, server)
+	if err != nil {
+		logger.Errorf("Start login flow for user %d failed: %s", user, err)
+		return err
+	}
+
+	n.mu.Lock()
+	if prev, ok := n.flows[user]; ok {
+		prev.cancel()
+	}
+	flowCtx, cancel := context.WithCancel(n.ctx)
+	pending := &pendingFlow{cancel: cancel}
+	n.flows[user] = pending
+	n.mu.Unlock()
+
+	n.sendMessage(user, fmt.Sprintf("Для входа в Nextcloud перейдите по ссылке: %s", flow.LoginURL))
+
+	go func() {
+		defer func() {
+			n.mu.Lock()
+			cancel()
+			if n.flows[user] == pending {
+				delete(n.flows, user)
+			}
+			n.mu.Unlock()
+		}()
+
+		credentials, err := flow.Wait(flowCtx, loginFlowPollInterval)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+			logger.Warnf("Login flow for user %d failed: %s", user, err)
+			n.sendMessage(user, loginFlowFailure(err))
+			return
+		}
+
+		if err = n.completeLoginFlow(user, credentials); err != nil {
+			logger.Errorf("Complete login flow for user %d failed: %s", user, err)
+			n.sendMessage(user, loginFlowFailure(err))
+			return
+		}
+		n.sendMessage(user, "Вход в Nextcloud выполнен")
+	}()
+
+	return nil
+}
+
+func (n *Notes) completeLoginFlow(user int32, credentials *nextcloud.AppCredentials) error {
+	u := model.NotesUser{
+		TelegramUser: user,
+		Endpoint:     nextcloud.DavEndpoint(credentials.Server),
+		Login:        credentials.Login,
+		Password:     credentials.AppPassword,
+		AppPassword:  true,
+	}
+
+	code, err := n.checkAccess(&u)
+	if err == nil {
+		switch code {
+		case rms_notes.UserLoginResponse_OK:
+		case rms_notes.UserLoginResponse_InvalidCredentials:
+			err = fmt.Errorf("%w: check access failed: %s", vault.ErrInvalidCredentials, code)
+		default:
+			err = fmt.Errorf("%w: check access failed: %s", vault.ErrUnreachable, code)
+		}
+	}
+	if err != nil {
+		n.revokeAppPassword(&u)
+		return err
+	}
+
+	return n.saveCredentials(&u)
+}
+
+// loginFlowFailure returns the message about the failed login flow for the user. The details are logged only
+func loginFlowFailure(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "Не удалось войти в Nextcloud: время ожидания истекло"
+	case errors.Is(err, vault.ErrInvalidCredentials):
+		return "Не удалось войти в Nextcloud: доступ запрещен"
+	case errors.Is(err, vault.ErrUnreachable):
+		return "Не удалось войти в Nextcloud: сервер недоступен"
+	default:
+		return "Не удалось войти в Nextcloud"
+	}
+}
+
+// revokeAppPassword deletes the app password issued by the login flow. Failures are logged only
+func (n *Notes) revokeAppPassword(u *model.NotesUser) {
+	if !u.AppPassword {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := nextcloud.RevokeAppPassword(ctx, nextcloud.ServerOf(u.Endpoint), u.Login, u.Password); err != nil {
+		logger.Warnf("Revoke app password of user %d failed: %s", u.TelegramUser, err)
+	}
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/vault"
+)
+
+func TestLoginFlowFailure(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{context.DeadlineExceeded, "Не удалось войти в Nextcloud: время ожидания истекло"},
+		{fmt.Errorf("%w: connection refused", vault.ErrUnreachable), "Не удалось войти в Nextcloud: сервер недоступен"},
+		{fmt.Errorf("%w: check access failed", vault.ErrInvalidCredentials), "Не удалось войти в Nextcloud: доступ запрещен"},
+		{errors.New("decode app credentials failed"), "Не удалось войти в Nextcloud"},
+	}
+	for _, tt := range tests {
+		if got := loginFlowFailure(tt.err); got != tt.want {
+			t.Errorf("%v: got '%s', want '%s'", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/model"
+	"github.com/RacoonMediaServer/rms-notes/internal/obsidian"
+	"go-micro.dev/v4/logger"
+)
+
+// isMemberUnsafe returns true if the user owns the vault or the vault is shared with the user
+func (v *userVault) isMemberUnsafe(user int32) bool {
+	if v.user() == user {
+		return true
+	}
+	_, ok := v.members[user]
+	return ok
+}
+
+// membersUnsafe returns the owner of the vault and the users the vault is shared with
+func (v *userVault) membersUnsafe() []int32 {
+	result := []int32{v.user()}
+	for u := range v.members {
+		if u != v.user() {
+			result = append(result, u)
+		}
+	}
+	sort.Slice(result[1:], func(i, j int) bool { return result[i+1] < result[j+1] })
+	return result
+}
+
+func (n *Notes) membersOf(v *userVault) []int32 {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return v.membersUnsafe()
+}
+
+// assigneesUnsafe maps '@' assignees of the task to the vault members. The task is unassigned if none of its
+// assignees is a member
+func (v *userVault) assigneesUnsafe(t *obsidian.Task) []int32 {
+	var result []int32
+	for _, alias := range t.Assignees {
+		for u, memberAlias := range v.members {
+			if memberAlias != "" && strings.EqualFold(memberAlias, alias) {
+				result = append(result, u)
+			}
+		}
+	}
+	return result
+}
+
+// tasksFor selects the tasks which should be reminded to the member: the tasks assigned to the member and the unassigned
+// ones
+func (n *Notes) tasksFor(v *userVault, user int32, tasks []*obsidian.Task) []*obsidian.Task {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	var result []*obsidian.Task
+	for _, t := range tasks {
+		assignees := v.assigneesUnsafe(t)
+		if len(assignees) == 0 {
+			result = append(result, t)
+			continue
+		}
+		for _, u := range assignees {
+			if u == user {
+				result = append(result, t)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// findOwnVaultUnsafe looks for the vault of the owner by the name
+func (n *Notes) findOwnVaultUnsafe(owner int32, name string) (*userVault, error) {
+	for _, v := range n.vaultsOfUnsafe(owner) {
+		if v.user() == owner && strings.EqualFold(v.cfg.Name, name) {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("vault not found: %s", name)
+}
+
+// ShareVault shares the vault of the owner with the member. The alias is used to assign tasks to the member, e.g.
+// "@alice". The owner may set an own alias by sharing the vault with oneself
+func (n *Notes) ShareVault(ctx context.Context, owner int32, name string, member int32, alias string) error {
+	alias = strings.TrimPrefix(strings.TrimSpace(alias), "@")
+	if alias != "" && strings.ContainsAny(alias, " \t") {
+		return fmt.Errorf("invalid alias: '%s'", alias)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	v, err := n.findOwnVaultUnsafe(owner, name)
+	if err != nil {
+		return err
+	}
+	if _, ok := n.users[member]; !ok {
+		return errors.New("member must login")
+	}
+	for u, a := range v.members {
+		if u != member && alias != "" && strings.EqualFold(a, alias) {
+			return fmt.Errorf("alias already used: %s", alias)
+		}
+	}
+
+	record := model.VaultMember{VaultID: v.cfg.ID, TelegramUser: member, Alias: alias}
+	if err = n.db.SaveVaultMember(&record); err != nil {
+		return fmt.Errorf("save vault member failed: %w", err)
+	}
+	v.members[member] = alias
+
+	logger.Infof("Vault '%s' of user %d is shared with user %d", v.cfg.Name, owner, member)
+	return nil
+}
+
+// UnshareVault stops sharing the vault of the owner with the member
+func (n *Notes) UnshareVault(ctx context.Context, owner int32, name string, member int32) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	v, err := n.findOwnVaultUnsafe(owner, name)
+	if err != nil {
+		return err
+	}
+	if err = n.db.RemoveVaultMember(v.cfg.ID, member); err != nil {
+		return fmt.Errorf("remove vault member failed: %w", err)
+	}
+	delete(v.members, member)
+	return nil
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/config"
+)
+
+func TestAssigneeRouting(t *testing.T) {
+	n, _, bot := newTestNotes(t, config.Configuration{})
+	for _, u := range []int32{1, 2, 3, 4} {
+		n.addTestUser(u, time.UTC)
+	}
+
+	overdue := " 📅 " + n.dateAfter(1, -1) + "\n"
+	v := n.addTestVault(t, 1, 1, map[string]string{
+		"tasks.md": "- [ ] buy milk @alice" + overdue +
+			"- [ ] fix the tap @Olga" + overdue +
+			"- [ ] walk the dog" + overdue +
+			"- [ ] call @bob" + overdue +
+			"- [ ] pay rent @alice @olga" + overdue,
+	}, nil)
+
+	ctx := context.Background()
+	// the owner sets the own alias by sharing the vault with oneself
+	if err := n.ShareVault(ctx, 1, v.cfg.Name, 1, "@olga"); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.ShareVault(ctx, 1, v.cfg.Name, 2, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.ShareVault(ctx, 1, v.cfg.Name, 3, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.ShareVault(ctx, 1, v.cfg.Name, 4, "ALICE"); err == nil {
+		t.Errorf("alias is used twice")
+	}
+
+	n.notifyAboutScheduledTasks(v, v.vault.GetTasks())
+
+	want := map[int32][]string{
+		1: {"call @bob", "fix the tap @Olga", "pay rent @alice @olga", "walk the dog"},
+		2: {"buy milk @alice", "call @bob", "pay rent @alice @olga", "walk the dog"},
+		3: {"call @bob", "walk the dog"},
+		4: nil,
+	}
+	for u, tasks := range want {
+		if got := taskTexts(bot.take(u)); !reflect.DeepEqual(got, tasks) {
+			t.Errorf("user %d: got %v, want %v", u, got, tasks)
+		}
+	}
+
+	// the tasks of the member who left the vault are unassigned
+	if err := n.UnshareVault(ctx, 1, v.cfg.Name, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := n.tasksFor(v, 1, v.vault.GetTasks()); len(got) != 5 {
+		t.Errorf("tasks of the owner after unsharing: %v", got)
+	}
+	if got := n.membersOf(v); !reflect.DeepEqual(got, []int32{1, 3}) {
+		t.Errorf("unexpected members: %v", got)
+	}
+}
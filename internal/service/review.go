@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/obsidian"
+	"go-micro.dev/v4/logger"
+)
+
+const (
+	defaultReviewFileName     = "Review {{.Year}}-W{{.Week}}"
+	defaultReviewSnoozedAfter = 3
+
+	// reviewPeriod is a number of days covered by the review
+	reviewPeriod = 7
+
+	// snoozeStatsTTL is a period after which statistics of not snoozed tasks are removed
+	snoozeStatsTTL = 90 * 24 * time.Hour
+)
+
+type reviewName struct {
+	Date string
+	Year int
+	Week string
+}
+
+// review contains sections of the weekly review note
+type review struct {
+	completed []*obsidian.Task
+	overdue   []*obsidian.Task
+	snoozed   []*obsidian.Task
+	upcoming  []*obsidian.Task
+	snoozes   map[string]int
+}
+
+func (n *Notes) isReviewDay(now time.Time) bool {
+	return n.cfg.Review.Enabled && int(now.Weekday()) == n.cfg.Review.Weekday
+}
+
+// WriteReview writes the weekly review notes of the user into the vaults
+func (n *Notes) WriteReview(ctx context.Context, user int32) error {
+	vaults := n.vaultsOf(user)
+	if len(vaults) == 0 {
+		return errors.New("user must login")
+	}
+
+	var result error
+	for _, v := range vaults {
+		if err := n.writeReview(v); err != nil {
+			result = err
+		}
+	}
+	return result
+}
+
+func (n *Notes) writeReview(v *userVault) error {
+	user := v.user()
+	today := n.userToday(user)
+	o := v.vault
+
+	tasks, err := o.CollectTasks(func(t *obsidian.Task) bool { return true })
+	if err != nil {
+		logger.Errorf("Collect tasks for review failed: %s", err)
+		return err
+	}
+
+	r := n.buildReview(tasks, n.snoozeStatsOf(v), today)
+	title, err := n.reviewTitle(today)
+	if err != nil {
+		return err
+	}
+
+	if err = o.AddNote(n.cfg.Review.Folder, title, r.format(o, title)); err != nil {
+		logger.Errorf("Write review note failed: %s", err)
+		return err
+	}
+
+	logger.Infof("Review note '%s' created", title)
+	return nil
+}
+
+// snoozeStatsOf sums the snoozes of the tasks by all members of the vault
+func (n *Notes) snoozeStatsOf(v *userVault) map[string]int {
+	result := map[string]int{}
+	for _, member := range n.membersOf(v) {
+		snoozes, err := n.db.LoadSnoozeStats(member)
+		if err != nil {
+			logger.Warnf("Load snooze stats of user %d failed: %s", member, err)
+			continue
+		}
+		for id, count := range snoozes {
+			result[id] += count
+		}
+	}
+	return result
+}
+
+func (n *Notes) buildReview(tasks []*obsidian.Task, snoozes map[string]int, today time.Time) *review {
+	snoozedAfter := n.cfg.Review.SnoozedAfter
+	if snoozedAfter <= 0 {
+		snoozedAfter = defaultReviewSnoozedAfter
+	}
+	weekAgo := today.AddDate(0, 0, -reviewPeriod)
+	nextWeek := today.AddDate(0, 0, reviewPeriod)
+
+	r := &review{snoozes: snoozes}
+	for _, t := range tasks {
+		if t.IsDone() {
+			if t.DoneDate != nil && !t.DoneDate.Before(weekAgo) && !t.DoneDate.After(today) {
+				r.completed = append(r.completed, t)
+			}
+			continue
+		}
+		if !t.IsOpen() {
+			continue
+		}
+		if snoozes[t.ID()] > snoozedAfter {
+			r.snoozed = append(r.snoozed, t)
+		}
+		date := t.ReminderDate()
+		if date == nil {
+			continue
+		}
+		switch {
+		case !date.Before(weekAgo) && date.Before(today):
+			r.overdue = append(r.overdue, t)
+		case !date.Before(today) && date.Before(nextWeek):
+			r.upcoming = append(r.upcoming, t)
+		}
+	}
+	return r
+}
+
+func (n *Notes) reviewTitle(today time.Time) (string, error) {
+	fileName := n.cfg.Review.FileName
+	if fileName == "" {
+		fileName = defaultReviewFileName
+	}
+	tmpl, err := template.New("review").Parse(fileName)
+	if err != nil {
+		return "", fmt.Errorf("invalid review file name template: %w", err)
+	}
+
+	year, week := today.ISOWeek()
+	name := reviewName{
+		Date: today.Format(obsidian.DateFormat),
+		Year: year,
+		Week: fmt.Sprintf("%02d", week),
+	}
+
+	var builder strings.Builder
+	if err = tmpl.Execute(&builder, &name); err != nil {
+		return "", fmt.Errorf("execute review file name template failed: %w", err)
+	}
+	return builder.String(), nil
+}
+
+func (r *review) format(o *obsidian.Vault, title string) string {
+	var builder strings.Builder
+	builder.WriteString("# " + title + "\n")
+
+	sections := []struct {
+		title string
+		tasks []*obsidian.Task
+	}{
+		{"Выполнено за неделю", r.completed},
+		{"Просрочено за неделю", r.overdue},
+		{"Часто откладываемые", r.snoozed},
+		{"На следующей неделе", r.upcoming},
+	}
+	for _, s := range sections {
+		builder.WriteString("\n## " + s.title + "\n")
+		if len(s.tasks) == 0 {
+			builder.WriteString("\nНет задач\n")
+			continue
+		}
+		r.formatSection(&builder, o, s.tasks)
+	}
+	return builder.String()
+}
+
+// formatSection writes the tasks grouped by source note
+func (r *review) formatSection(builder *strings.Builder, o *obsidian.Vault, tasks []*obsidian.Task) {
+	groups := map[string][]*obsidian.Task{}
+	var notes []string
+	for _, t := range tasks {
+		if _, ok := groups[t.Note]; !ok {
+			notes = append(notes, t.Note)
+		}
+		groups[t.Note] = append(groups[t.Note], t)
+	}
+	sort.Strings(notes)
+
+	for _, note := range notes {
+		path := o.NotePath(note)
+		builder.WriteString(fmt.Sprintf("\n### [[%s]]\n", strings.TrimSuffix(path, ".md")))
+		for _, t := range groups[note] {
+			builder.WriteString("- " + t.Text)
+			if count := r.snoozes[t.ID()]; count != 0 {
+				builder.WriteString(fmt.Sprintf(" (отложено %d раз)", count))
+			}
+			if t.DoneDate != nil {
+				builder.WriteString(" ✅ " + t.DoneDate.Format(obsidian.DateFormat))
+			} else if date := t.ReminderDate(); date != nil {
+				builder.WriteString(" 📅 " + date.Format(obsidian.DateFormat))
+			}
+			builder.WriteString("\n")
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/config"
+	"github.com/RacoonMediaServer/rms-notes/internal/obsidian"
+)
+
+func TestBuildReview(t *testing.T) {
+	n, db, _ := newTestNotes(t, config.Configuration{Review: config.Review{SnoozedAfter: 3}})
+	n.addTestUser(1, time.UTC)
+	n.addTestUser(2, time.UTC)
+	today := n.userToday(1)
+
+	v := n.addTestVault(t, 1, 1, map[string]string{
+		"tasks.md": "- [ ] snoozed by both 📅 " + n.dateAfter(1, 10) + " ^both\n" +
+			"- [ ] snoozed by the owner 📅 " + n.dateAfter(1, 10) + " ^owner\n" +
+			"- [x] done ✅ " + n.dateAfter(1, -2) + " 📅 " + n.dateAfter(1, -3) + " ^done\n" +
+			"- [x] done long ago ✅ " + n.dateAfter(1, -20) + " ^old\n" +
+			"- [ ] overdue 📅 " + n.dateAfter(1, -3) + " ^overdue\n" +
+			"- [ ] upcoming 📅 " + n.dateAfter(1, 6) + " ^upcoming\n" +
+			"- [ ] snoozed by nobody 📅 " + n.dateAfter(1, 20) + " ^nobody\n",
+	}, map[int32]string{2: "alice"})
+
+	snooze := func(user int32, id string, times int) {
+		for i := 0; i < times; i++ {
+			_ = db.IncSnoozeCount(user, id, time.Now())
+		}
+	}
+	snooze(1, "both", 2)
+	snooze(2, "both", 2)
+	snooze(1, "owner", 3)
+	// the snoozes of the tasks from other vaults are not listed
+	snooze(2, "unknown", 5)
+
+	tasks, err := v.vault.CollectTasks(func(t *obsidian.Task) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := n.buildReview(tasks, n.snoozeStatsOf(v), today)
+	texts := func(tasks []*obsidian.Task) []string {
+		var result []string
+		for _, t := range tasks {
+			result = append(result, t.Text)
+		}
+		return result
+	}
+	sections := []struct {
+		name string
+		got  []*obsidian.Task
+		want []string
+	}{
+		{"completed", r.completed, []string{"done"}},
+		{"overdue", r.overdue, []string{"overdue"}},
+		{"snoozed", r.snoozed, []string{"snoozed by both"}},
+		{"upcoming", r.upcoming, []string{"upcoming"}},
+	}
+	for _, s := range sections {
+		if got := texts(s.got); !reflect.DeepEqual(got, s.want) {
+			t.Errorf("%s: got %v, want %v", s.name, got, s.want)
+		}
+	}
+	if r.snoozes["both"] != 4 {
+		t.Errorf("snoozes are not summed: %v", r.snoozes)
+	}
+}
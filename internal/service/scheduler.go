@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/RacoonMediaServer/rms-notes/internal/model"
 	"github.com/RacoonMediaServer/rms-notes/internal/obsidian"
 	"github.com/RacoonMediaServer/rms-packages/pkg/communication"
 	rms_bot_client "github.com/RacoonMediaServer/rms-packages/pkg/service/rms-bot-client"
@@ -20,60 +21,225 @@ func (n *Notes) runScheduleEvents() {
 		n.sched.RemoveByReference(n.job)
 		n.job = nil
 	}
-	notifyTime := fmt.Sprintf("%02d:00", n.settings.NotificationTime)
-	n.job, _ = n.sched.Every(1).Day().At(notifyTime).Do(func() {
+	nextMinute := time.Now().Truncate(time.Minute).Add(time.Minute)
+	n.job, _ = n.sched.Every(1).Minute().StartAt(nextMinute).Do(n.dispatch)
+}
+
+// dailyVault is a vault which members receive the daily notifications now
+type dailyVault struct {
+	v     *userVault
+	users []int32
+}
+
+// dispatch runs every minute: it sends the reminders of the timed tasks and the daily digest at the notification time
+// of each user in the user's time zone. The vaults are refreshed for the digest in background, so the timed reminders
+// are not delayed by them
+func (n *Notes) dispatch() {
+	now := time.Now().Truncate(time.Minute)
+
+	n.sendTimedReminders(now)
+
+	n.mu.RLock()
+	vaults := n.dailyVaultsUnsafe(now)
+	notificationTime := n.settings.NotificationTime
+	n.mu.RUnlock()
+
+	if len(vaults) != 0 {
+		go n.sendDailyNotifications(vaults)
+	}
+
+	if now.Hour() == int(notificationTime) && now.Minute() == 0 {
+		if err := n.db.RemoveSentReminders(now.AddDate(0, 0, -1)); err != nil {
+			logger.Warnf("Remove outdated reminders failed: %s", err)
+		}
+		if err := n.db.RemoveReminderStats(now.Add(-reminderStatsTTL)); err != nil {
+			logger.Warnf("Remove outdated reminder stats failed: %s", err)
+		}
+		if err := n.db.RemoveSnoozeStats(now.Add(-snoozeStatsTTL)); err != nil {
+			logger.Warnf("Remove outdated snooze stats failed: %s", err)
+		}
+	}
+}
+
+// dailyVaultsUnsafe returns the vaults which members have the notification time now in their time zones
+func (n *Notes) dailyVaultsUnsafe(now time.Time) []dailyVault {
+	var vaults []dailyVault
+	for _, v := range n.vaults {
+		p := dailyVault{v: v}
+		for _, u := range v.membersUnsafe() {
+			userNow := now.In(n.locationOfUnsafe(u))
+			if userNow.Hour() == int(n.settingsOfUnsafe(u).NotificationTime) && userNow.Minute() == 0 {
+				p.users = append(p.users, u)
+			}
+		}
+		if len(p.users) != 0 {
+			vaults = append(vaults, p)
+		}
+	}
+	return vaults
+}
+
+// sendTimedReminders sends the reminders which time has come. The reminders missed today, e.g. while the service was
+// stopped, are sent too, but every reminder is sent only once
+func (n *Notes) sendTimedReminders(now time.Time) {
+	for id, reminders := range n.timed.due(now) {
 		n.mu.RLock()
-		vaults := n.vaults
+		v, ok := n.vaults[id]
 		n.mu.RUnlock()
+		if !ok {
+			continue
+		}
+		tasks := make([]*obsidian.Task, len(reminders))
+		at := map[*obsidian.Task]time.Time{}
+		for i, r := range reminders {
+			tasks[i] = r.task
+			at[r.task] = r.at.UTC()
+		}
+		for _, u := range n.membersOf(v) {
+			for _, t := range n.tasksFor(v, u, tasks) {
+				sent := &model.SentReminder{TelegramUser: u, TaskID: t.ID(), DueDate: at[t]}
+				if ok, err := n.db.IsReminderSent(sent); err != nil || ok {
+					continue
+				}
+				logger.Infof("Task reminder time: %s", t)
+				n.sendTaskNotification(u, formatTask(n.vaultTitle(u, v, "Напоминание"), t), t)
+				sent.SentAt = time.Now()
+				if err := n.db.AddSentReminder(sent); err != nil {
+					logger.Warnf("Save reminder of task %s failed: %s", t.ID(), err)
+				}
+			}
+		}
+	}
+}
 
-		for u, v := range vaults {
-			logger.Infof("Refreshing vault %d...", u)
-			if err := v.Refresh(obsidian.Scheduled); err != nil {
-				logger.Logf(logger.ErrorLevel, "Refresh vault %d failed: %s", u, err)
+// sendDailyNotifications refreshes the vaults and sends the daily notifications to their members. The shared vault is
+// refreshed once for all members
+func (n *Notes) sendDailyNotifications(vaults []dailyVault) {
+	for _, p := range vaults {
+		logger.Infof("Refreshing vault %d of user %d...", p.v.cfg.ID, p.v.user())
+		if err := p.v.vault.Refresh(obsidian.Scheduled); err != nil {
+			logger.Logf(logger.ErrorLevel, "Refresh vault %d failed: %s", p.v.cfg.ID, err)
+		}
+		tasks := p.v.vault.GetTasks()
+		for _, u := range p.users {
+			n.notifyUserAboutScheduledTasks(p.v, u, n.tasksFor(p.v, u, tasks))
+			if u == p.v.user() && n.isReviewDay(n.userToday(u)) {
+				_ = n.writeReview(p.v)
 			}
-			n.notifyAboutScheduledTasks(u, v.GetTasks())
 		}
-	})
+	}
 }
 
-func (n *Notes) notifyAboutScheduledTasks(user int32, tasks []*obsidian.Task) {
-	now := time.Now()
-	now = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+// notifyAboutScheduledTasks sends reminders to every member of the vault about the tasks assigned to the member and
+// the unassigned ones
+func (n *Notes) notifyAboutScheduledTasks(v *userVault, tasks []*obsidian.Task) {
+	for _, u := range n.membersOf(v) {
+		n.notifyUserAboutScheduledTasks(v, u, n.tasksFor(v, u, tasks))
+	}
+}
+
+func (n *Notes) notifyUserAboutScheduledTasks(v *userVault, user int32, tasks []*obsidian.Task) {
+	now := n.userToday(user)
+
+	digest := n.isDigestMode(user)
+	agenda := newAgenda()
 
+	var stale []*obsidian.Task
 	for _, t := range tasks {
-		if t.DueDate == nil || t.Done {
+		date := t.ReminderDate()
+		if date == nil || !t.IsOpen() || t.Blocked || !t.IsStarted(now) {
 			continue
 		}
-		if now.Compare(*t.DueDate) >= 0 {
+		if t.Time != nil && !date.Before(now) {
+			// reminded at the exact time by the dispatcher
+			continue
+		}
+		if now.Compare(*date) >= 0 {
 			logger.Infof("Task is expired: %s", t)
-
-			_, err := n.bot.SendMessage(context.Background(), &rms_bot_client.SendMessageRequest{Message: &communication.BotMessage{
-				Type: communication.MessageType_Interaction,
-				Text: formatTask(t),
-				Buttons: []*communication.Button{
-					{
-						Title:   "Отложить",
-						Command: fmt.Sprintf("/tasks snooze %s", t.Hash()),
-					},
-					{
-						Title:   "Выполнить",
-						Command: fmt.Sprintf("/tasks done %s", t.Hash()),
-					},
-					{
-						Title:   "Удалить",
-						Command: fmt.Sprintf("/tasks remove %s", t.Hash()),
-					},
-				},
-				KeyboardStyle: communication.KeyboardStyle_Message,
-				Attachment:    nil,
-				User:          user,
-			}})
-
-			if err != nil {
-				logger.Errorf("Send notification failed: %s", err)
+			level, count := n.trackReminder(user, t, *date, now)
+			switch {
+			case level == levelStale:
+				stale = append(stale, t)
+			case digest:
+				agenda.add(t, *date, now, level)
+			default:
+				n.sendEscalatedReminder(v, user, t, *date, now, level, count)
 			}
 		}
 	}
+
+	if digest {
+		advance := n.dueAdvanceReminders(user, tasks)
+		agenda.addUpcoming(tasks, now, n.upcomingDays(user))
+		agenda.addAdvance(advance)
+		n.sendAgenda(v, user, agenda)
+		for _, r := range advance {
+			n.markAdvanceReminder(r)
+		}
+	} else {
+		n.notifyAboutUpcomingTasks(v, user, tasks)
+	}
+
+	if n.isStaleDigestDay(now) {
+		n.notifyAboutStaleTasks(v, user, stale)
+	}
+}
+
+func (n *Notes) notifyAboutUnblockedTasks(v *userVault, tasks []*obsidian.Task) {
+	for _, u := range n.membersOf(v) {
+		for _, t := range n.tasksFor(v, u, tasks) {
+			logger.Infof("Task is unblocked: %s", t)
+			n.sendTaskNotification(u, formatTask(n.vaultTitle(u, v, "Задача разблокирована"), t), t)
+		}
+	}
+}
+
+func (n *Notes) notifyAboutOpenSubtasks(v *userVault, user int32, subtasks []*obsidian.Task) {
+	title := n.vaultTitle(user, v, "Остались незавершенные подзадачи")
+	n.sendMessage(user, "<b>"+title+"</b>\n\n"+formatSubtasks(subtasks, 0))
+}
+
+func (n *Notes) sendMessage(user int32, text string) {
+	_, err := n.bot.SendMessage(context.Background(), &rms_bot_client.SendMessageRequest{Message: &communication.BotMessage{Text: text, User: user}})
+	if err != nil {
+		logger.Errorf("Send notification failed: %s", err)
+	}
+}
+
+func (n *Notes) sendTaskNotification(user int32, text string, t *obsidian.Task) {
+	n.sendInteraction(user, text, taskButtons(t))
+}
+
+func (n *Notes) sendInteraction(user int32, text string, buttons []*communication.Button) {
+	_, err := n.bot.SendMessage(context.Background(), &rms_bot_client.SendMessageRequest{Message: &communication.BotMessage{
+		Type:          communication.MessageType_Interaction,
+		Text:          text,
+		Buttons:       buttons,
+		KeyboardStyle: communication.KeyboardStyle_Message,
+		Attachment:    nil,
+		User:          user,
+	}})
+
+	if err != nil {
+		logger.Errorf("Send notification failed: %s", err)
+	}
+}
+
+func taskButtons(t *obsidian.Task) []*communication.Button {
+	return []*communication.Button{
+		{
+			Title:   "Отложить",
+			Command: fmt.Sprintf("/tasks snooze %s", t.ID()),
+		},
+		{
+			Title:   "Выполнить",
+			Command: fmt.Sprintf("/tasks done %s", t.ID()),
+		},
+		{
+			Title:   "Удалить",
+			Command: fmt.Sprintf("/tasks remove %s", t.ID()),
+		},
+	}
 }
 
 func (n *Notes) notifyAboutError(user int32, err error) {
@@ -91,6 +257,11 @@ func (n *Notes) notifyAboutError(user int32, err error) {
 			msg = fmt.Sprintf("Не удалось удалить задачу '%s'", obsidianErr.Item)
 		case obsidian.ErrDoneTaskFailed:
 			msg = fmt.Sprintf("Не удалось завершить задачу '%s'", obsidianErr.Item)
+		case obsidian.ErrSetTaskStatusFailed:
+			msg = fmt.Sprintf("Не удалось изменить статус задачи '%s'", obsidianErr.Item)
+		default:
+			logger.Warnf("Background job failed: %s", err)
+			return
 		}
 		_, err := n.bot.SendMessage(context.Background(), &rms_bot_client.SendMessageRequest{Message: &communication.BotMessage{Text: msg, User: user}})
 		if err != nil {
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/config"
+	"github.com/RacoonMediaServer/rms-notes/internal/model"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func parseTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	result, err := time.Parse("2006-01-02 15:04", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestSendTimedReminders(t *testing.T) {
+	n, _, bot := newTestNotes(t, config.Configuration{})
+	n.addTestUser(1, time.UTC)
+	v := n.addTestVault(t, 1, 1, map[string]string{
+		"tasks.md": "- [ ] call mom ⏰ 10:00 📅 2026-10-20\n" +
+			"- [ ] pay rent ⏰ 8:30 📅 2026-10-20\n" +
+			"- [ ] water plants ⏰ 12:00 📅 2026-10-21\n" +
+			"- [ ] buy milk 📅 2026-10-20\n" +
+			"- [x] feed the cat ⏰ 9:00 📅 2026-10-20\n" +
+			"- [ ] old task ⏰ 9:00 📅 2026-10-19\n",
+	}, nil)
+
+	n.timed.rebuild(v.cfg.ID, v.vault.GetTasks(), parseTime(t, "2026-10-20 09:00"))
+
+	steps := []struct {
+		now     string
+		rebuild bool
+		want    []string
+	}{
+		{now: "2026-10-20 09:00", want: []string{"pay rent"}},
+		{now: "2026-10-20 09:59"},
+		{now: "2026-10-20 10:00", want: []string{"call mom"}},
+		{now: "2026-10-20 10:01"},
+		// the vault is updated, the reminders of today are given out again, but they have been sent already
+		{now: "2026-10-20 10:05", rebuild: true},
+		{now: "2026-10-21 12:00", want: []string{"water plants"}},
+	}
+	for _, s := range steps {
+		now := parseTime(t, s.now)
+		if s.rebuild {
+			n.timed.rebuild(v.cfg.ID, v.vault.GetTasks(), now)
+		}
+		n.sendTimedReminders(now)
+		if got := taskTexts(bot.take(1)); !reflect.DeepEqual(got, s.want) {
+			t.Errorf("%s: got %v, want %v", s.now, got, s.want)
+		}
+	}
+}
+
+func TestDailyVaultsInTimeZones(t *testing.T) {
+	n, _, _ := newTestNotes(t, config.Configuration{})
+	n.addTestUser(1, loadLocation(t, "Europe/Moscow"))
+	n.addTestUser(2, loadLocation(t, "America/New_York"))
+	n.addTestUser(3, time.UTC)
+	early := uint32(7)
+	n.userSettings[3].NotificationTime = &early
+
+	n.addTestVault(t, 1, 1, nil, map[int32]string{2: "bob"})
+	n.addTestVault(t, 2, 3, nil, nil)
+
+	tests := []struct {
+		now  string
+		want map[uint][]int32
+	}{
+		// the notification time is 9:00 of the user
+		{now: "2026-10-20 06:00", want: map[uint][]int32{1: {1}}},
+		{now: "2026-10-20 13:00", want: map[uint][]int32{1: {2}}},
+		// New York leaves the daylight saving time
+		{now: "2026-11-20 14:00", want: map[uint][]int32{1: {2}}},
+		{now: "2026-10-20 07:00", want: map[uint][]int32{2: {3}}},
+		{now: "2026-10-20 09:00", want: map[uint][]int32{}},
+		{now: "2026-10-20 06:01", want: map[uint][]int32{}},
+	}
+	for _, tt := range tests {
+		got := map[uint][]int32{}
+		for _, p := range n.dailyVaultsUnsafe(parseTime(t, tt.now)) {
+			users := append([]int32{}, p.users...)
+			sort.Slice(users, func(i, j int) bool { return users[i] < users[j] })
+			got[p.v.cfg.ID] = users
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestTimedRemindersInTimeZones(t *testing.T) {
+	n, _, bot := newTestNotes(t, config.Configuration{})
+	moscow := loadLocation(t, "Europe/Moscow")
+	n.addTestUser(1, moscow)
+	v := n.addTestVault(t, 1, 1, map[string]string{"tasks.md": "- [ ] call mom ⏰ 10:00 📅 2026-10-20\n"}, nil)
+
+	// the reminders are built in the time zone of the vault owner
+	n.timed.rebuild(v.cfg.ID, v.vault.GetTasks(), parseTime(t, "2026-10-20 05:00").In(moscow))
+
+	n.sendTimedReminders(parseTime(t, "2026-10-20 06:59"))
+	if got := bot.take(1); len(got) != 0 {
+		t.Errorf("reminder is sent before the time: %v", got)
+	}
+	n.sendTimedReminders(parseTime(t, "2026-10-20 07:00"))
+	if got := taskTexts(bot.take(1)); !reflect.DeepEqual(got, []string{"call mom"}) {
+		t.Errorf("unexpected reminders: %v", got)
+	}
+}
+
+func TestSetUserSettingsTimeZone(t *testing.T) {
+	n, _, _ := newTestNotes(t, config.Configuration{})
+	n.addTestUser(1, nil)
+	if n.location(1) != time.Local {
+		t.Errorf("server time zone is not used by default")
+	}
+
+	zone := "Asia/Tokyo"
+	if err := n.SetUserSettings(context.Background(), &model.UserSettings{TelegramUser: 1, TimeZone: &zone}); err != nil {
+		t.Fatal(err)
+	}
+	if got := n.location(1).String(); got != zone {
+		t.Errorf("got %s, want %s", got, zone)
+	}
+
+	invalid := "Mars/Olympus"
+	if err := n.SetUserSettings(context.Background(), &model.UserSettings{TelegramUser: 1, TimeZone: &invalid}); err == nil {
+		t.Errorf("invalid time zone is accepted")
+	}
+	if got := n.location(1).String(); got != zone {
+		t.Errorf("time zone is changed by the invalid one: %s", got)
+	}
+
+	if err := n.SetUserSettings(context.Background(), &model.UserSettings{TelegramUser: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if n.location(1) != time.Local {
+		t.Errorf("time zone is not reset")
+	}
+}
@@ -6,9 +6,10 @@ import (
 	"fmt"
 	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/RacoonMediaServer/rms-notes/internal/config"
 	"github.com/RacoonMediaServer/rms-notes/internal/model"
-	"github.com/RacoonMediaServer/rms-notes/internal/nextcloud"
 	"github.com/RacoonMediaServer/rms-notes/internal/obsidian"
 	"github.com/RacoonMediaServer/rms-packages/pkg/pubsub"
 	rms_bot_client "github.com/RacoonMediaServer/rms-packages/pkg/service/rms-bot-client"
@@ -26,29 +27,30 @@ type Notes struct {
 	db    Database
 	pub   micro.Event
 	bot   rms_bot_client.RmsBotClientService
-	async bool
+	cfg   config.Configuration
 	sched *gocron.Scheduler
-
-	mu       sync.RWMutex
-	settings *rms_notes.NotesSettings
-	users    map[int32]*model.NotesUser
-	vaults   map[int32]*obsidian.Vault
-	job      *gocron.Job
-	ctx      context.Context
-	cancel   context.CancelFunc
+	timed *dispatcher
+
+	mu           sync.RWMutex
+	settings     *rms_notes.NotesSettings
+	userSettings map[int32]*model.UserSettings
+	locations    map[int32]*time.Location
+	users        map[int32]*model.NotesUser
+	vaults       map[uint]*userVault
+	flows        map[int32]*pendingFlow
+	job          *gocron.Job
+	ctx          context.Context
+	cancel       context.CancelFunc
 }
 
 // RemoveTask implements rms_notes.RmsNotesHandler.
 func (n *Notes) RemoveTask(ctx context.Context, request *rms_notes.RemoveTaskRequest, response *emptypb.Empty) error {
-	n.mu.RLock()
-	o, ok := n.vaults[request.User]
-	n.mu.RUnlock()
-
-	if !ok {
-		return errors.New("user must login")
+	o, err := n.findTaskVault(request.User, request.Id)
+	if err != nil {
+		return err
 	}
 
-	if err := o.RemoveTask(request.Id); err != nil {
+	if err = o.vault.RemoveTask(request.Id); err != nil {
 		logger.Errorf("Remove task %s failed: %s", request.Id, err)
 		return err
 	}
@@ -58,15 +60,14 @@ func (n *Notes) RemoveTask(ctx context.Context, request *rms_notes.RemoveTaskReq
 
 // SendTasksNotification implements rms_notes.RmsNotesHandler.
 func (n *Notes) SendTasksNotification(ctx context.Context, request *rms_notes.SendTasksNotificationRequest, response *emptypb.Empty) error {
-	n.mu.RLock()
-	o, ok := n.vaults[request.User]
-	n.mu.RUnlock()
-
-	if !ok {
+	vaults := n.vaultsOf(request.User)
+	if len(vaults) == 0 {
 		return errors.New("user must login")
 	}
 
-	n.notifyAboutScheduledTasks(request.User, o.GetTasks())
+	for _, v := range vaults {
+		n.notifyUserAboutScheduledTasks(v, request.User, n.tasksFor(v, request.User, v.vault.GetTasks()))
+	}
 	return nil
 }
 
@@ -81,54 +82,43 @@ func (n *Notes) IsUserLogged(ctx context.Context, request *rms_notes.IsUserLogge
 func (n *Notes) UserLogin(ctx context.Context, request *rms_notes.UserLoginRequest, response *rms_notes.UserLoginResponse) error {
 	user := model.NotesUser{
 		TelegramUser: request.User,
+		Backend:      model.BackendNextcloud,
 		Endpoint:     request.Endpoint,
 		Login:        request.Login,
 		Password:     request.Password,
 	}
 
-	// TODO: check access
-	if err := n.db.AddUser(&user); err != nil {
-		return fmt.Errorf("add user to database failed: %w", err)
-	}
-
-	n.mu.Lock()
-	defer n.mu.Unlock()
-	n.users[request.User] = &user
-	n.vaults[request.User] = n.createVault(&user, n.settings.Directory)
-
-	return nil
+	// the second login replaces the credentials and restarts the vaults of the user. Other backends are logged in by the
+	// Login method of the extension API
+	code, err := n.Login(ctx, &user)
+	response.Code = code
+	return err
 }
 
 func (n *Notes) AddNote(ctx context.Context, request *rms_notes.AddNoteRequest, empty *emptypb.Empty) error {
-	n.mu.RLock()
-	o, ok := n.vaults[request.User]
-	notesDirectory := n.settings.NotesDirectory
-	n.mu.RUnlock()
-
-	if !ok {
-		return errors.New("user must login")
+	o, title, err := n.selectVault(request.User, request.Title)
+	if err != nil {
+		return err
 	}
+	notesDirectory := n.settingsOfVault(o.cfg).NotesDirectory
 
-	if err := o.AddNote(notesDirectory, request.Title, request.Text); err != nil {
+	if err = o.vault.AddNote(notesDirectory, title, request.Text); err != nil {
 		logger.Errorf("Create a new note failed: %s", err)
 		return err
 	}
 
-	logger.Infof("Note '%s' created", request.Title)
+	logger.Infof("Note '%s' created", title)
 	return nil
 }
 
 func (n *Notes) AddTask(ctx context.Context, request *rms_notes.AddTaskRequest, empty *emptypb.Empty) error {
-	n.mu.RLock()
-	o, ok := n.vaults[request.User]
-	tasksFile := n.settings.TasksFile
-	n.mu.RUnlock()
-
-	if !ok {
-		return errors.New("user must login")
+	o, text, err := n.selectVault(request.User, request.Text)
+	if err != nil {
+		return err
 	}
+	tasksFile := n.settingsOfVault(o.cfg).TasksFile
 
-	t := obsidian.Task{Text: request.Text}
+	t := obsidian.Task{Text: text, Status: obsidian.StatusSymbolTodo}
 	if request.DueDate != nil {
 		date, err := time.Parse(obsidian.DateFormat, *request.DueDate)
 		if err != nil {
@@ -138,7 +128,7 @@ func (n *Notes) AddTask(ctx context.Context, request *rms_notes.AddTaskRequest,
 		}
 		t.DueDate = &date
 	}
-	if err := o.AddTask(tasksFile, &t); err != nil {
+	if err = o.vault.AddTask(tasksFile, &t); err != nil {
 		logger.Errorf("Add task failed: %s", err)
 		return err
 	}
@@ -146,44 +136,79 @@ func (n *Notes) AddTask(ctx context.Context, request *rms_notes.AddTaskRequest,
 }
 
 func (n *Notes) SnoozeTask(ctx context.Context, request *rms_notes.SnoozeTaskRequest, empty *emptypb.Empty) error {
-	n.mu.RLock()
-	o, ok := n.vaults[request.User]
-	n.mu.RUnlock()
-
-	if !ok {
-		return errors.New("user must login")
+	o, err := n.findTaskVault(request.User, request.Id)
+	if err != nil {
+		return err
 	}
 
-	date := time.Now().AddDate(0, 0, 1)
-	var err error
+	date := n.userToday(request.User).AddDate(0, 0, 1)
 	if request.DueDate != nil {
 		date, err = time.Parse(obsidian.DateFormat, *request.DueDate)
 		if err != nil {
 			return fmt.Errorf("invalid date format: %s", err)
 		}
 	}
-	if err = o.SnoozeTask(request.Id, date); err != nil {
+	taskId := request.Id
+	if t, ok := o.vault.GetTask(request.Id); ok {
+		taskId = t.ID()
+	}
+	if err = o.vault.SnoozeTask(request.Id, date); err != nil {
 		logger.Errorf("Cannot snooze task %s to %s: %s", request.Id, date, err)
 		return err
 	}
+	if err = n.db.IncSnoozeCount(request.User, taskId, time.Now()); err != nil {
+		logger.Warnf("Count snooze of task %s failed: %s", taskId, err)
+	}
 
 	return nil
 }
 
 func (n *Notes) DoneTask(ctx context.Context, request *rms_notes.DoneTaskRequest, empty *emptypb.Empty) error {
-	n.mu.RLock()
-	o, ok := n.vaults[request.User]
-	n.mu.RUnlock()
+	o, err := n.findTaskVault(request.User, request.Id)
+	if err != nil {
+		return err
+	}
 
-	if !ok {
-		return errors.New("user must login")
+	var subtasks []*obsidian.Task
+	if t, ok := o.vault.GetTask(request.Id); ok {
+		subtasks = t.Subtasks
 	}
 
-	if err := o.DoneTask(request.Id); err != nil {
+	completeSubtasks := n.completeSubtasks(request.User)
+	if completeSubtasks {
+		err = o.vault.DoneTaskWithSubtasks(request.Id)
+	} else {
+		err = o.vault.DoneTask(request.Id)
+	}
+	if err != nil {
 		logger.Errorf("Done task %s failed: %s", request.Id, err)
 		return err
 	}
 
+	if len(subtasks) != 0 && !completeSubtasks {
+		n.notifyAboutOpenSubtasks(o, request.User, subtasks)
+	}
+
+	return nil
+}
+
+// SetTaskStatus moves the task to the status with the symbol, e.g. "/" for in-progress or "-" for cancelled
+func (n *Notes) SetTaskStatus(ctx context.Context, user int32, id string, status string) error {
+	o, err := n.findTaskVault(user, id)
+	if err != nil {
+		return err
+	}
+
+	symbol, size := utf8.DecodeRuneInString(status)
+	if size == 0 || size != len(status) {
+		return fmt.Errorf("invalid status: '%s'", status)
+	}
+
+	if err = o.vault.SetTaskStatus(id, symbol); err != nil {
+		logger.Errorf("Set status of task %s failed: %s", id, err)
+		return err
+	}
+
 	return nil
 }
 
@@ -215,18 +240,15 @@ func (n *Notes) SetSettings(ctx context.Context, settings *rms_notes.NotesSettin
 	n.cancel()
 	n.ctx, n.cancel = context.WithCancel(context.Background())
 
-	n.vaults = make(map[int32]*obsidian.Vault)
-	for _, u := range n.users {
-		n.vaults[u.TelegramUser] = n.createVault(u, settings.Directory)
+	for _, v := range n.vaults {
+		n.replaceVaultUnsafe(v)
 	}
 	n.mu.Unlock()
 
-	n.runScheduleEvents()
-
 	return nil
 }
 
-func New(db Database, s servicemgr.ClientFactory, async bool) (*Notes, error) {
+func New(db Database, s servicemgr.ClientFactory, cfg config.Configuration) (*Notes, error) {
 	settings, err := db.LoadSettings()
 	if err != nil {
 		return nil, err
@@ -237,26 +259,58 @@ func New(db Database, s servicemgr.ClientFactory, async bool) (*Notes, error) {
 		return nil, err
 	}
 
+	userSettings, err := db.LoadUserSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	configs, err := db.LoadVaults()
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := db.LoadVaultMembers()
+	if err != nil {
+		return nil, err
+	}
+
 	pub := pubsub.NewPublisher(s)
 	f := servicemgr.NewServiceFactory(s)
 	bot := f.NewBotClient()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	n := &Notes{
-		db:       db,
-		pub:      pub,
-		async:    async,
-		bot:      bot,
-		users:    users,
-		settings: settings,
-		vaults:   make(map[int32]*obsidian.Vault),
-		sched:    gocron.NewScheduler(time.Local),
-		ctx:      ctx,
-		cancel:   cancel,
+		db:           db,
+		pub:          pub,
+		cfg:          cfg,
+		bot:          bot,
+		users:        users,
+		settings:     settings,
+		userSettings: userSettings,
+		locations:    locationsOf(userSettings),
+		vaults:       make(map[uint]*userVault),
+		flows:        make(map[int32]*pendingFlow),
+		sched:        gocron.NewScheduler(time.Local),
+		timed:        newDispatcher(),
+		ctx:          ctx,
+		cancel:       cancel,
 	}
 
 	for _, u := range users {
-		n.vaults[u.TelegramUser] = n.createVault(u, settings.Directory)
+		if configs, err = n.ensureDefaultVaultUnsafe(u.TelegramUser, configs); err != nil {
+			return nil, err
+		}
+	}
+	for _, cfg := range configs {
+		if u, ok := users[cfg.TelegramUser]; ok {
+			vaultMembers := map[int32]string{}
+			for _, m := range members {
+				if m.VaultID == cfg.ID {
+					vaultMembers[m.TelegramUser] = m.Alias
+				}
+			}
+			n.vaults[cfg.ID] = n.createVault(u, cfg, vaultMembers)
+		}
 	}
 
 	n.runScheduleEvents()
@@ -264,38 +318,3 @@ func New(db Database, s servicemgr.ClientFactory, async bool) (*Notes, error) {
 
 	return n, nil
 }
-
-func (n *Notes) createVault(user *model.NotesUser, directory string) *obsidian.Vault {
-	webDav := nextcloud.WebDAV{
-		Root:     user.Endpoint,
-		User:     user.Login,
-		Password: user.Password,
-	}
-
-	errHandler := func(err error) {
-		n.notifyAboutError(user.TelegramUser, err)
-	}
-
-	vault := obsidian.NewVault(n.ctx, directory, nextcloud.NewClient(webDav), errHandler, n.async)
-	vaultId := fmt.Sprintf("[%s / %d]", user.Login, user.TelegramUser)
-
-	go func() {
-		for {
-			select {
-			case <-n.ctx.Done():
-				return
-			case <-time.After(refreshRetryInterval):
-				logger.Infof("Refreshing new vault %s...", vaultId)
-				if err := vault.Refresh(obsidian.Scheduled); err == nil {
-					logger.Infof("Tasks for vault %s loaded", vaultId)
-					vault.StartWatchingChanges()
-					n.notifyAboutScheduledTasks(user.TelegramUser, vault.GetTasks())
-					return
-				} else {
-					logger.Errorf("Refresh obsidian vault %s failed: %s", vaultId, err)
-				}
-			}
-		}
-	}()
-	return vault
-}
@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/config"
+	"github.com/RacoonMediaServer/rms-notes/internal/folder"
+	"github.com/RacoonMediaServer/rms-notes/internal/model"
+	"github.com/RacoonMediaServer/rms-notes/internal/obsidian"
+	rms_bot_client "github.com/RacoonMediaServer/rms-packages/pkg/service/rms-bot-client"
+	rms_notes "github.com/RacoonMediaServer/rms-packages/pkg/service/rms-notes"
+	"go-micro.dev/v4/client"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// fakeDatabase keeps the records in memory
+type fakeDatabase struct {
+	mu            sync.Mutex
+	notifications map[int32]*model.NotificationSettings
+	sent          map[sentKey]*model.SentReminder
+	stats         map[sentKey]*model.ReminderStats
+	snoozes       map[int32]map[string]int
+}
+
+type sentKey struct {
+	user       int32
+	task       string
+	dueDate    int64
+	daysBefore int
+}
+
+func newFakeDatabase() *fakeDatabase {
+	return &fakeDatabase{
+		notifications: map[int32]*model.NotificationSettings{},
+		sent:          map[sentKey]*model.SentReminder{},
+		stats:         map[sentKey]*model.ReminderStats{},
+		snoozes:       map[int32]map[string]int{},
+	}
+}
+
+func (d *fakeDatabase) LoadSettings() (*rms_notes.NotesSettings, error) {
+	return &rms_notes.NotesSettings{}, nil
+}
+
+func (d *fakeDatabase) SaveSettings(val *rms_notes.NotesSettings) error {
+	return nil
+}
+
+func (d *fakeDatabase) LoadUserSettings() (map[int32]*model.UserSettings, error) {
+	return map[int32]*model.UserSettings{}, nil
+}
+
+func (d *fakeDatabase) GetUserSettings(user int32) (*model.UserSettings, error) {
+	return &model.UserSettings{TelegramUser: user}, nil
+}
+
+func (d *fakeDatabase) SaveUserSettings(settings *model.UserSettings) error {
+	return nil
+}
+
+func (d *fakeDatabase) LoadUsers() (map[int32]*model.NotesUser, error) {
+	return map[int32]*model.NotesUser{}, nil
+}
+
+func (d *fakeDatabase) AddUser(user *model.NotesUser) error {
+	return nil
+}
+
+func (d *fakeDatabase) RemoveUser(id int32) error {
+	return nil
+}
+
+func (d *fakeDatabase) LoadVaults() ([]*model.NotesVault, error) {
+	return nil, nil
+}
+
+func (d *fakeDatabase) AddVault(vault *model.NotesVault) error {
+	return nil
+}
+
+func (d *fakeDatabase) RemoveVault(id uint) error {
+	return nil
+}
+
+func (d *fakeDatabase) LoadVaultMembers() ([]*model.VaultMember, error) {
+	return nil, nil
+}
+
+func (d *fakeDatabase) SaveVaultMember(member *model.VaultMember) error {
+	return nil
+}
+
+func (d *fakeDatabase) RemoveVaultMember(vaultId uint, user int32) error {
+	return nil
+}
+
+func (d *fakeDatabase) LoadNotificationSettings(user int32) (*model.NotificationSettings, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if settings, ok := d.notifications[user]; ok {
+		result := *settings
+		return &result, nil
+	}
+	return &model.NotificationSettings{TelegramUser: user}, nil
+}
+
+func (d *fakeDatabase) SaveNotificationSettings(settings *model.NotificationSettings) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	record := *settings
+	d.notifications[settings.TelegramUser] = &record
+	return nil
+}
+
+func keyOf(r *model.SentReminder) sentKey {
+	return sentKey{user: r.TelegramUser, task: r.TaskID, dueDate: r.DueDate.Unix(), daysBefore: r.DaysBefore}
+}
+
+func (d *fakeDatabase) IsReminderSent(r *model.SentReminder) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.sent[keyOf(r)]
+	return ok, nil
+}
+
+func (d *fakeDatabase) AddSentReminder(r *model.SentReminder) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	record := *r
+	d.sent[keyOf(r)] = &record
+	return nil
+}
+
+func (d *fakeDatabase) RemoveSentReminders(before time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for k, r := range d.sent {
+		if r.DueDate.Before(before) {
+			delete(d.sent, k)
+		}
+	}
+	return nil
+}
+
+func (d *fakeDatabase) LoadReminderStats(user int32, taskId string, dueDate time.Time) (*model.ReminderStats, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if stats, ok := d.stats[sentKey{user: user, task: taskId, dueDate: dueDate.Unix()}]; ok {
+		result := *stats
+		return &result, nil
+	}
+	return &model.ReminderStats{TelegramUser: user, TaskID: taskId, DueDate: dueDate}, nil
+}
+
+func (d *fakeDatabase) SaveReminderStats(stats *model.ReminderStats) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	record := *stats
+	d.stats[sentKey{user: stats.TelegramUser, task: stats.TaskID, dueDate: stats.DueDate.Unix()}] = &record
+	return nil
+}
+
+func (d *fakeDatabase) RemoveReminderStats(before time.Time) error {
+	return nil
+}
+
+func (d *fakeDatabase) IncSnoozeCount(user int32, taskId string, now time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.snoozes[user] == nil {
+		d.snoozes[user] = map[string]int{}
+	}
+	d.snoozes[user][taskId]++
+	return nil
+}
+
+func (d *fakeDatabase) LoadSnoozeStats(user int32) (map[string]int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	result := map[string]int{}
+	for id, count := range d.snoozes[user] {
+		result[id] = count
+	}
+	return result, nil
+}
+
+func (d *fakeDatabase) RemoveSnoozeStats(before time.Time) error {
+	return nil
+}
+
+// fakeBot records the sent messages
+type fakeBot struct {
+	mu       sync.Mutex
+	messages []*rms_bot_client.SendMessageRequest
+}
+
+func (b *fakeBot) GetIdentificationCode(ctx context.Context, in *emptypb.Empty, opts ...client.CallOption) (*rms_bot_client.GetIdentificationCodeResponse, error) {
+	return &rms_bot_client.GetIdentificationCodeResponse{}, nil
+}
+
+func (b *fakeBot) SendMessage(ctx context.Context, in *rms_bot_client.SendMessageRequest, opts ...client.CallOption) (*emptypb.Empty, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages = append(b.messages, in)
+	return &emptypb.Empty{}, nil
+}
+
+// take returns the texts of the messages sent to the user since the previous call and forgets them
+func (b *fakeBot) take(user int32) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var result []string
+	rest := b.messages[:0]
+	for _, m := range b.messages {
+		if m.Message.User == user {
+			result = append(result, m.Message.Text)
+		} else {
+			rest = append(rest, m)
+		}
+	}
+	b.messages = rest
+	return result
+}
+
+// newTestNotes creates the service without the background jobs
+func newTestNotes(t *testing.T, cfg config.Configuration) (*Notes, *fakeDatabase, *fakeBot) {
+	db := newFakeDatabase()
+	bot := &fakeBot{}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	n := &Notes{
+		db:           db,
+		bot:          bot,
+		cfg:          cfg,
+		timed:        newDispatcher(),
+		settings:     &rms_notes.NotesSettings{NotificationTime: 9},
+		userSettings: map[int32]*model.UserSettings{},
+		locations:    map[int32]*time.Location{},
+		users:        map[int32]*model.NotesUser{},
+		vaults:       map[uint]*userVault{},
+		flows:        map[int32]*pendingFlow{},
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+	return n, db, bot
+}
+
+// addTestUser registers the user in the time zone, nil location means the server time zone
+func (n *Notes) addTestUser(user int32, loc *time.Location) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.users[user] = &model.NotesUser{TelegramUser: user}
+	if loc != nil {
+		name := loc.String()
+		n.userSettings[user] = &model.UserSettings{TelegramUser: user, TimeZone: &name}
+		n.locations[user] = loc
+	}
+}
+
+// addTestVault creates the vault of the owner in the folder with the notes. The members are mapped to their aliases
+func (n *Notes) addTestVault(t *testing.T, id uint, owner int32, notes map[string]string, members map[int32]string) *userVault {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range notes {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if members == nil {
+		members = map[int32]string{}
+	}
+
+	ctx, cancel := context.WithCancel(n.ctx)
+	t.Cleanup(cancel)
+	accessor := folder.NewAccessor()
+	vault := obsidian.NewVault(ctx, dir, accessor, nil, false)
+	vault.SetLocation(n.location(owner))
+	if err := vault.Refresh(obsidian.Scheduled); err != nil {
+		t.Fatal(err)
+	}
+
+	v := &userVault{
+		cfg:      &model.NotesVault{ID: id, TelegramUser: owner, Name: fmt.Sprintf("Vault %d", id)},
+		vault:    vault,
+		accessor: accessor,
+		cancel:   cancel,
+		members:  members,
+	}
+	n.mu.Lock()
+	n.vaults[id] = v
+	n.mu.Unlock()
+	return v
+}
+
+// taskTexts extracts the task texts of the sent messages
+func taskTexts(messages []string) []string {
+	var result []string
+	for _, m := range messages {
+		for _, line := range strings.Split(m, "\n") {
+			if text, ok := strings.CutPrefix(line, "<b>Задача:</b> "); ok {
+				result = append(result, text)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// dateAfter returns the date shifted by the days from today of the user, formatted as in the notes
+func (n *Notes) dateAfter(user int32, days int) string {
+	return n.userToday(user).AddDate(0, 0, days).Format(obsidian.DateFormat)
+}
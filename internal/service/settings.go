@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/model"
+	rms_notes "github.com/RacoonMediaServer/rms-packages/pkg/service/rms-notes"
+	"go-micro.dev/v4/logger"
+)
+
+// settingsOfUnsafe returns the settings of the user merged with the global ones
+func (n *Notes) settingsOfUnsafe(user int32) *rms_notes.NotesSettings {
+	result := &rms_notes.NotesSettings{
+		Directory:        n.settings.Directory,
+		NotesDirectory:   n.settings.NotesDirectory,
+		TasksFile:        n.settings.TasksFile,
+		NotificationTime: n.settings.NotificationTime,
+	}
+
+	custom, ok := n.userSettings[user]
+	if !ok {
+		return result
+	}
+	if custom.Directory != nil {
+		result.Directory = *custom.Directory
+	}
+	if custom.NotesDirectory != nil {
+		result.NotesDirectory = *custom.NotesDirectory
+	}
+	if custom.TasksFile != nil {
+		result.TasksFile = *custom.TasksFile
+	}
+	if custom.NotificationTime != nil {
+		result.NotificationTime = *custom.NotificationTime
+	}
+	return result
+}
+
+func (n *Notes) settingsOf(user int32) *rms_notes.NotesSettings {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.settingsOfUnsafe(user)
+}
+
+// GetUserSettings returns the effective settings of the user
+func (n *Notes) GetUserSettings(ctx context.Context, user int32) (*rms_notes.NotesSettings, error) {
+	return n.settingsOf(user), nil
+}
+
+// SetUserSettings replaces personal settings of the user. Nil fields are reset to the global settings. Only the vaults
+// owned by the user are recreated if their directory is changed, the vaults shared with the user are kept intact
+func (n *Notes) SetUserSettings(ctx context.Context, settings *model.UserSettings) error {
+	if settings.NotificationTime != nil && *settings.NotificationTime > 23 {
+		return fmt.Errorf("invalid notification time: %d", *settings.NotificationTime)
+	}
+	loc := time.Local
+	if settings.TimeZone != nil {
+		var err error
+		if loc, err = time.LoadLocation(*settings.TimeZone); err != nil {
+			return fmt.Errorf("invalid time zone: %w", err)
+		}
+	}
+
+	if err := n.db.SaveUserSettings(settings); err != nil {
+		logger.Errorf("Save settings of user %d failed: %s", settings.TelegramUser, err)
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var vaults []*userVault
+	for _, v := range n.vaultsOfUnsafe(settings.TelegramUser) {
+		if v.user() == settings.TelegramUser {
+			vaults = append(vaults, v)
+		}
+	}
+	prevDirectories := make([]string, len(vaults))
+	for i, v := range vaults {
+		prevDirectories[i] = n.settingsOfVaultUnsafe(v.cfg).Directory
+	}
+	n.userSettings[settings.TelegramUser] = settings
+	n.locations[settings.TelegramUser] = loc
+
+	for i, v := range vaults {
+		if n.settingsOfVaultUnsafe(v.cfg).Directory != prevDirectories[i] {
+			n.replaceVaultUnsafe(v)
+		} else {
+			v.vault.SetLocation(loc)
+			n.timed.rebuild(v.cfg.ID, v.vault.GetTasks(), time.Now().In(loc))
+		}
+	}
+	return nil
+}
+
+// completeSubtasks returns true if the open subtasks of the user are completed together with the parent task
+func (n *Notes) completeSubtasks(user int32) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	custom, ok := n.userSettings[user]
+	if !ok || custom.CompleteSubtasks == nil {
+		return n.cfg.CompleteSubtasks
+	}
+	return *custom.CompleteSubtasks
+}
+
+// timeZoneOf returns the time zone set by the user, nil means the server time zone
+func (n *Notes) timeZoneOf(user int32) *string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if custom, ok := n.userSettings[user]; ok {
+		return custom.TimeZone
+	}
+	return nil
+}
+
+// locationsOf resolves the time zones of the users once, so they are not loaded on every check of the schedule. The
+// server time zone is used by default
+func locationsOf(settings map[int32]*model.UserSettings) map[int32]*time.Location {
+	result := make(map[int32]*time.Location, len(settings))
+	for user, custom := range settings {
+		if custom.TimeZone == nil {
+			continue
+		}
+		loc, err := time.LoadLocation(*custom.TimeZone)
+		if err != nil {
+			logger.Warnf("Invalid time zone of user %d: %s", user, err)
+			continue
+		}
+		result[user] = loc
+	}
+	return result
+}
+
+// locationOfUnsafe returns the time zone of the user. The server time zone is used by default
+func (n *Notes) locationOfUnsafe(user int32) *time.Location {
+	if loc, ok := n.locations[user]; ok {
+		return loc
+	}
+	return time.Local
+}
+
+func (n *Notes) location(user int32) *time.Location {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.locationOfUnsafe(user)
+}
+
+// userNow returns the current time in the time zone of the user
+func (n *Notes) userNow(user int32) time.Time {
+	return time.Now().In(n.location(user))
+}
+
+// userToday returns the current date of the user as midnight UTC, the same way as dates are parsed from notes
+func (n *Notes) userToday(user int32) time.Time {
+	now := n.userNow(user)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
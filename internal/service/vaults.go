@@ -0,0 +1,318 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/model"
+	"github.com/RacoonMediaServer/rms-notes/internal/obsidian"
+	vaultpkg "github.com/RacoonMediaServer/rms-notes/internal/vault"
+	rms_notes "github.com/RacoonMediaServer/rms-packages/pkg/service/rms-notes"
+	"go-micro.dev/v4/logger"
+)
+
+// defaultVaultName is a name of the vault created for the user on login
+const defaultVaultName = "Default"
+
+// userVault is a running Obsidian vault of the user
+type userVault struct {
+	cfg      *model.NotesVault
+	vault    *obsidian.Vault
+	accessor vaultpkg.Accessor
+	cancel   context.CancelFunc
+	members  map[int32]string
+}
+
+func (v *userVault) user() int32 {
+	return v.cfg.TelegramUser
+}
+
+// vaultsOfUnsafe returns the vaults of the user ordered by creation
+func (n *Notes) vaultsOfUnsafe(user int32) []*userVault {
+	var result []*userVault
+	for _, v := range n.vaults {
+		if v.isMemberUnsafe(user) {
+			result = append(result, v)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].cfg.ID < result[j].cfg.ID })
+	return result
+}
+
+func (n *Notes) vaultsOf(user int32) []*userVault {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.vaultsOfUnsafe(user)
+}
+
+// defaultVaultUnsafe returns the own vault marked as default or the first vault of the user
+func (n *Notes) defaultVaultUnsafe(user int32) (*userVault, bool) {
+	vaults := n.vaultsOfUnsafe(user)
+	if len(vaults) == 0 {
+		return nil, false
+	}
+	for _, v := range vaults {
+		if v.cfg.Default && v.user() == user {
+			return v, true
+		}
+	}
+	return vaults[0], true
+}
+
+// selectVault chooses the vault by the "[Name]" prefix of the text. The default vault is used if there is no prefix
+func (n *Notes) selectVault(user int32, text string) (*userVault, string, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if strings.HasPrefix(text, "[") {
+		if end := strings.Index(text, "]"); end > 0 {
+			name := strings.TrimSpace(text[1:end])
+			for _, v := range n.vaultsOfUnsafe(user) {
+				if strings.EqualFold(v.cfg.Name, name) {
+					return v, strings.TrimSpace(text[end+1:]), nil
+				}
+			}
+		}
+	}
+
+	v, ok := n.defaultVaultUnsafe(user)
+	if !ok {
+		return nil, "", errors.New("user must login")
+	}
+	return v, text, nil
+}
+
+// findTaskVault looks for the vault which contains the task. The default vault is returned if the task is not found
+func (n *Notes) findTaskVault(user int32, id string) (*userVault, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	for _, v := range n.vaultsOfUnsafe(user) {
+		if _, ok := v.vault.GetTask(id); ok {
+			return v, nil
+		}
+	}
+
+	v, ok := n.defaultVaultUnsafe(user)
+	if !ok {
+		return nil, errors.New("user must login")
+	}
+	return v, nil
+}
+
+// vaultTitle labels the notification title with the vault name if the user has several vaults
+func (n *Notes) vaultTitle(user int32, v *userVault, title string) string {
+	if len(n.vaultsOf(user)) < 2 {
+		return title
+	}
+	return fmt.Sprintf("[%s] %s", v.cfg.Name, title)
+}
+
+// settingsOfVaultUnsafe returns the settings of the user overridden by the paths of the vault
+func (n *Notes) settingsOfVaultUnsafe(cfg *model.NotesVault) *rms_notes.NotesSettings {
+	result := n.settingsOfUnsafe(cfg.TelegramUser)
+	if cfg.Directory != "" {
+		result.Directory = cfg.Directory
+	}
+	if cfg.NotesDirectory != "" {
+		result.NotesDirectory = cfg.NotesDirectory
+	}
+	if cfg.TasksFile != "" {
+		result.TasksFile = cfg.TasksFile
+	}
+	return result
+}
+
+func (n *Notes) settingsOfVault(cfg *model.NotesVault) *rms_notes.NotesSettings {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.settingsOfVaultUnsafe(cfg)
+}
+
+// GetVaults returns the vaults of the user without their secrets
+func (n *Notes) GetVaults(ctx context.Context, user int32) ([]*model.NotesVault, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if _, ok := n.users[user]; !ok {
+		return nil, errors.New("user must login")
+	}
+
+	var result []*model.NotesVault
+	for _, v := range n.vaultsOfUnsafe(user) {
+		cfg := *v.cfg
+		cfg.Password = ""
+		cfg.Token = ""
+		cfg.Headers = nil
+		cfg.PrivateKey = ""
+		result = append(result, &cfg)
+	}
+	return result, nil
+}
+
+// AddVault registers a new named vault of the user
+func (n *Notes) AddVault(ctx context.Context, vault *model.NotesVault) error {
+	vault.Name = strings.TrimSpace(vault.Name)
+	if vault.Name == "" || strings.ContainsAny(vault.Name, "[]") {
+		return fmt.Errorf("invalid vault name: '%s'", vault.Name)
+	}
+
+	if vault.Backend != "" && !isKnownBackend(vault.Backend) {
+		return fmt.Errorf("unknown backend: %s", vault.Backend)
+	}
+
+	n.mu.RLock()
+	u, ok := n.users[vault.TelegramUser]
+	n.mu.RUnlock()
+	if !ok {
+		return errors.New("user must login")
+	}
+	if vault.Endpoint != "" {
+		if err := validateEndpoint(backendOf(u, vault)); err != nil {
+			return err
+		}
+	}
+	if err := pinVaultHostKey(u, vault); err != nil {
+		return fmt.Errorf("pin host key failed: %w", err)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if u, ok = n.users[vault.TelegramUser]; !ok {
+		return errors.New("user must login")
+	}
+	if _, err := n.findOwnVaultUnsafe(vault.TelegramUser, vault.Name); err == nil {
+		return fmt.Errorf("vault already exists: %s", vault.Name)
+	}
+
+	vault.ID = 0
+	if err := n.db.AddVault(vault); err != nil {
+		return fmt.Errorf("add vault to database failed: %w", err)
+	}
+	n.vaults[vault.ID] = n.createVault(u, vault, map[int32]string{})
+
+	logger.Infof("Vault '%s' of user %d added", vault.Name, vault.TelegramUser)
+	return nil
+}
+
+// RemoveVault removes the named vault of the user. The last own vault cannot be removed
+func (n *Notes) RemoveVault(ctx context.Context, user int32, name string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	v, err := n.findOwnVaultUnsafe(user, name)
+	if err != nil {
+		return err
+	}
+	own := 0
+	for _, other := range n.vaultsOfUnsafe(user) {
+		if other.user() == user {
+			own++
+		}
+	}
+	if own < 2 {
+		return errors.New("the last vault cannot be removed")
+	}
+
+	if err = n.db.RemoveVault(v.cfg.ID); err != nil {
+		return fmt.Errorf("remove vault from database failed: %w", err)
+	}
+	n.stopVaultUnsafe(v)
+	delete(n.vaults, v.cfg.ID)
+	return nil
+}
+
+// ensureDefaultVaultUnsafe creates the default vault of the user if the user has no vaults
+func (n *Notes) ensureDefaultVaultUnsafe(user int32, configs []*model.NotesVault) ([]*model.NotesVault, error) {
+	for _, cfg := range configs {
+		if cfg.TelegramUser == user {
+			return configs, nil
+		}
+	}
+	cfg := &model.NotesVault{TelegramUser: user, Name: defaultVaultName, Default: true}
+	if err := n.db.AddVault(cfg); err != nil {
+		return configs, fmt.Errorf("add default vault failed: %w", err)
+	}
+	return append(configs, cfg), nil
+}
+
+// stopVaultUnsafe cancels background jobs of the vault and closes the connection to the storage, e.g. the SFTP session
+func (n *Notes) stopVaultUnsafe(v *userVault) {
+	v.cancel()
+	n.timed.remove(v.cfg.ID)
+	if closer, ok := v.accessor.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logger.Warnf("Close storage of vault %d failed: %s", v.cfg.ID, err)
+		}
+	}
+}
+
+// replaceVaultUnsafe stops the vault and creates a new one with the same configuration
+func (n *Notes) replaceVaultUnsafe(v *userVault) {
+	n.stopVaultUnsafe(v)
+	if u, ok := n.users[v.user()]; ok {
+		n.vaults[v.cfg.ID] = n.createVault(u, v.cfg, v.members)
+	}
+}
+
+// cloneDirectory returns a path to the local clone of the vault kept in git
+func (n *Notes) cloneDirectory(cfg *model.NotesVault) string {
+	return filepath.Join(n.cfg.Git.Directory, strconv.FormatUint(uint64(cfg.ID), 10))
+}
+
+// createVault creates the vault of the user with its own context. Must be called under the lock
+func (n *Notes) createVault(user *model.NotesUser, cfg *model.NotesVault, members map[int32]string) *userVault {
+	backend := backendOf(user, cfg)
+	backend.Clone = n.cloneDirectory(cfg)
+	accessor, err := newAccessor(backend)
+	if err != nil {
+		logger.Errorf("Open storage of vault %d failed: %s", cfg.ID, err)
+		accessor = vaultpkg.Unavailable(err)
+	}
+
+	errHandler := func(err error) {
+		n.notifyAboutError(user.TelegramUser, err)
+	}
+
+	ctx, cancel := context.WithCancel(n.ctx)
+	directory := n.settingsOfVaultUnsafe(cfg).Directory
+	vault := obsidian.NewVault(ctx, directory, accessor, errHandler, n.cfg.Async)
+	vault.SetLocation(n.locationOfUnsafe(user.TelegramUser))
+
+	v := &userVault{cfg: cfg, vault: vault, accessor: accessor, cancel: cancel, members: members}
+	vault.OnUnblocked(func(tasks []*obsidian.Task) {
+		n.notifyAboutUnblockedTasks(v, tasks)
+	})
+	vault.OnUpdated(func() {
+		n.timed.rebuild(cfg.ID, vault.GetTasks(), n.userNow(user.TelegramUser))
+	})
+	vaultId := fmt.Sprintf("[%s / %d / %s]", backend.Login, user.TelegramUser, cfg.Name)
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(refreshRetryInterval):
+				logger.Infof("Refreshing new vault %s...", vaultId)
+				if err := vault.Refresh(obsidian.Scheduled); err == nil {
+					logger.Infof("Tasks for vault %s loaded", vaultId)
+					vault.StartWatchingChanges()
+					n.notifyAboutScheduledTasks(v, vault.GetTasks())
+					return
+				} else {
+					logger.Errorf("Refresh obsidian vault %s failed: %s", vaultId, err)
+				}
+			}
+		}
+	}()
+	return v
+}
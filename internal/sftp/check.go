@@ -0,0 +1,66 @@
+package sftp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/vault"
+	"golang.org/x/crypto/ssh"
+)
+
+// ScanHostKey connects to the server and returns its public key in the authorized_keys format. It is used to pin
+// the host key on the first login
+func ScanHostKey(addr string) (string, error) {
+	var hostKey ssh.PublicKey
+	config := &ssh.ClientConfig{
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			hostKey = key
+			return nil
+		},
+		Timeout: dialTimeout,
+	}
+
+	conn, err := ssh.Dial("tcp", address(addr), config)
+	if err == nil {
+		_ = conn.Close()
+	}
+	if hostKey == nil {
+		return "", fmt.Errorf("%w: %s", vault.ErrUnreachable, err)
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(hostKey))), nil
+}
+
+// Check verifies the host key, the credentials and the vault directory
+func Check(config Config, directory string) error {
+	cfg, err := clientConfig(config)
+	if err != nil {
+		return fmt.Errorf("%w: %s", vault.ErrUnreachable, err)
+	}
+
+	conn, client, err := dial(address(config.Address), cfg)
+	if err != nil {
+		if strings.Contains(err.Error(), "unable to authenticate") {
+			return vault.ErrInvalidCredentials
+		}
+		return fmt.Errorf("%w: %s", vault.ErrUnreachable, err)
+	}
+	defer conn.Close()
+	defer client.Close()
+
+	fi, err := client.Stat(directory)
+	switch {
+	case err == nil && !fi.IsDir():
+		return fmt.Errorf("%w: %s is not a directory", vault.ErrDirectoryNotFound, directory)
+	case err == nil:
+		return nil
+	case errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("%w: %s", vault.ErrDirectoryNotFound, directory)
+	case errors.Is(err, os.ErrPermission):
+		return vault.ErrInvalidCredentials
+	default:
+		return fmt.Errorf("%w: %s", vault.ErrUnreachable, err)
+	}
+}
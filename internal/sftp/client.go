@@ -0,0 +1,258 @@
+package sftp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/vault"
+	pkgsftp "github.com/pkg/sftp"
+	"go-micro.dev/v4/logger"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultPort = "22"
+	dialTimeout = 15 * time.Second
+)
+
+// ErrHostKeyNotPinned is returned when the connection has no pinned host key
+var ErrHostKeyNotPinned = errors.New("host key is not pinned")
+
+// errClosed is returned by the closed client
+var errClosed = errors.New("client is closed")
+
+// Config describes a connection to the SFTP server
+type Config struct {
+	// Address is a host of the server with an optional port, e.g. "home.lan:2222" or "sftp://home.lan"
+	Address string
+	User    string
+	// Password is used for password authentication or as a passphrase of the private key
+	Password string
+	// PrivateKey is a PEM encoded key for key-based authentication
+	PrivateKey string
+	// HostKey is a pinned public key of the server in the authorized_keys format
+	HostKey string
+}
+
+type Client struct {
+	config *ssh.ClientConfig
+	addr   string
+	l      logger.Logger
+
+	mu     sync.Mutex
+	conn   *ssh.Client
+	c      *pkgsftp.Client
+	closed bool
+}
+
+// address normalizes the address of the server to host:port
+func address(addr string) string {
+	addr = strings.TrimSuffix(strings.TrimPrefix(addr, "sftp://"), "/")
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return net.JoinHostPort(addr, defaultPort)
+	}
+	return addr
+}
+
+func clientConfig(config Config) (*ssh.ClientConfig, error) {
+	if config.HostKey == "" {
+		return nil, ErrHostKeyNotPinned
+	}
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(config.HostKey))
+	if err != nil {
+		return nil, fmt.Errorf("parse host key failed: %w", err)
+	}
+
+	var auth []ssh.AuthMethod
+	if config.PrivateKey != "" {
+		var signer ssh.Signer
+		if config.Password != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(config.PrivateKey), []byte(config.Password))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(config.PrivateKey))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse private key failed: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	} else {
+		auth = append(auth, ssh.Password(config.Password))
+	}
+
+	return &ssh.ClientConfig{
+		User:            config.User,
+		Auth:            auth,
+		HostKeyCallback: ssh.FixedHostKey(hostKey),
+		Timeout:         dialTimeout,
+	}, nil
+}
+
+func NewClient(config Config) (vault.Accessor, error) {
+	cfg, err := clientConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		config: cfg,
+		addr:   address(config.Address),
+		l:      logger.Fields(map[string]interface{}{"from": "sftp"}),
+	}, nil
+}
+
+func dial(addr string, config *ssh.ClientConfig) (*ssh.Client, *pkgsftp.Client, error) {
+	conn, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := pkgsftp.NewClient(conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	return conn, c, nil
+}
+
+// session returns the established connection or connects to the server
+func (c *Client) session() (*pkgsftp.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.c != nil {
+		return c.c, nil
+	}
+	if c.closed {
+		return nil, errClosed
+	}
+	conn, client, err := dial(c.addr, c.config)
+	if err != nil {
+		return nil, err
+	}
+	c.conn, c.c = conn, client
+	return client, nil
+}
+
+// reset closes the broken connection, the next call connects again
+func (c *Client) reset(client *pkgsftp.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.c != client {
+		return
+	}
+	_ = c.c.Close()
+	_ = c.conn.Close()
+	c.c, c.conn = nil, nil
+}
+
+// do runs the operation over the shared connection. The operation is retried once over a new connection if the
+// connection has been lost
+func (c *Client) do(fn func(client *pkgsftp.Client) error) error {
+	for attempt := 0; ; attempt++ {
+		client, err := c.session()
+		if err != nil {
+			return err
+		}
+		err = fn(client)
+		if !isConnectionError(err) || attempt != 0 {
+			return err
+		}
+		c.l.Logf(logger.WarnLevel, "Connection lost, reconnect: %s", err)
+		c.reset(client)
+	}
+}
+
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, pkgsftp.ErrSSHFxConnectionLost) || errors.Is(err, io.EOF) || errors.As(err, &netErr)
+}
+
+func (c *Client) Read(path string) ([]byte, error) {
+	var content []byte
+	err := c.do(func(client *pkgsftp.Client) error {
+		f, err := client.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		content, err = io.ReadAll(f)
+		return err
+	})
+	return content, err
+}
+
+func (c *Client) List(path string) ([]os.FileInfo, error) {
+	var result []os.FileInfo
+	err := c.do(func(client *pkgsftp.Client) (err error) {
+		result, err = client.ReadDir(path)
+		return
+	})
+	return result, err
+}
+
+func (c *Client) Write(path string, content []byte) error {
+	return c.do(func(client *pkgsftp.Client) error {
+		if err := client.MkdirAll(pathpkg.Dir(path)); err != nil {
+			return err
+		}
+		f, err := client.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+		if err != nil {
+			return err
+		}
+		if _, err = f.Write(content); err != nil {
+			_ = f.Close()
+			return err
+		}
+		return f.Close()
+	})
+}
+
+func (c *Client) Walk(root string, fn filepath.WalkFunc) error {
+	client, err := c.session()
+	if err != nil {
+		return fn(root, nil, err)
+	}
+
+	w := client.Walk(root)
+	for w.Step() {
+		if isConnectionError(w.Err()) {
+			c.reset(client)
+		}
+		err = fn(w.Path(), w.Stat(), w.Err())
+		if err == filepath.SkipDir {
+			if w.Stat() != nil && w.Stat().IsDir() {
+				w.SkipDir()
+			}
+			continue
+		}
+		if err == filepath.SkipAll {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the connection to the server. The client does not connect again after that
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	if c.c == nil {
+		return nil
+	}
+	_ = c.c.Close()
+	err := c.conn.Close()
+	c.c, c.conn = nil, nil
+	return err
+}
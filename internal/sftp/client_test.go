@@ -0,0 +1,381 @@
+package sftp
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/fs"
+	"net"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/vault"
+	pkgsftp "github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	testUser     = "alice"
+	testPassword = "secret"
+)
+
+// testServer is an in-process SFTP server over the in-memory file system
+type testServer struct {
+	addr    string
+	hostKey string
+	// privateKey is a PEM encoded key of the user accepted by the server
+	privateKey string
+
+	mu    sync.Mutex
+	conns []*ssh.ServerConn
+}
+
+func newKey(t *testing.T) (ssh.Signer, string) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+func authorizedKey(key ssh.PublicKey) string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+func newTestServer(t *testing.T, files map[string]string) *testServer {
+	t.Helper()
+	hostSigner, _ := newKey(t)
+	userSigner, privateKey := newKey(t)
+	userKey := authorizedKey(userSigner.PublicKey())
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if c.User() == testUser && string(password) == testPassword {
+				return nil, nil
+			}
+			return nil, errors.New("wrong password")
+		},
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if c.User() == testUser && authorizedKey(key) == userKey {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	s := &testServer{
+		addr:       listener.Addr().String(),
+		hostKey:    authorizedKey(hostSigner.PublicKey()),
+		privateKey: privateKey,
+	}
+	handlers := pkgsftp.InMemHandler()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, config, handlers)
+		}
+	}()
+	t.Cleanup(s.dropConnections)
+
+	if len(files) != 0 {
+		c := s.newClient(t, Config{})
+		for name, content := range files {
+			if err = c.Write(name, []byte(content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return s
+}
+
+func (s *testServer) serve(conn net.Conn, config *ssh.ServerConfig, handlers pkgsftp.Handlers) {
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	s.mu.Lock()
+	s.conns = append(s.conns, serverConn)
+	s.mu.Unlock()
+
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(ok, nil)
+				if ok {
+					go func() {
+						_ = pkgsftp.NewRequestServer(channel, handlers).Serve()
+						_ = channel.Close()
+					}()
+				}
+			}
+		}()
+	}
+}
+
+// dropConnections breaks all established connections like a restart of the server does
+func (s *testServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+}
+
+// config returns the password credentials with the pinned host key
+func (s *testServer) config() Config {
+	return Config{Address: "sftp://" + s.addr, User: testUser, Password: testPassword, HostKey: s.hostKey}
+}
+
+func (s *testServer) newClient(t *testing.T, override Config) *Client {
+	t.Helper()
+	config := s.config()
+	if override.Password != "" || override.PrivateKey != "" {
+		config.Password, config.PrivateKey = override.Password, override.PrivateKey
+	}
+	accessor, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := accessor.(*Client)
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestClient(t *testing.T) {
+	s := newTestServer(t, map[string]string{
+		"/vault/tasks.md":         "- [ ] task",
+		"/vault/notes/idea.md":    "idea",
+		"/vault/.obsidian/app.md": "{}",
+	})
+	c := s.newClient(t, Config{})
+
+	content, err := c.Read("/vault/tasks.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "- [ ] task" {
+		t.Errorf("unexpected content: %s", content)
+	}
+	if _, err = c.Read("/vault/missing.md"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected missing file, got %v", err)
+	}
+
+	if err = c.Write("/vault/notes/new.md", []byte("new note")); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Write("/vault/notes/new.md", []byte("short")); err != nil {
+		t.Fatal(err)
+	}
+	if content, err = c.Read("/vault/notes/new.md"); err != nil || string(content) != "short" {
+		t.Errorf("file is not overwritten: %s, %v", content, err)
+	}
+
+	files, err := c.List("/vault/notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"idea.md", "new.md"}) {
+		t.Errorf("unexpected files: %v", names)
+	}
+
+	var walked []string
+	err = c.Walk("/vault", func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".obsidian" {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() {
+			walked = append(walked, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(walked)
+	want := []string{"/vault/notes/idea.md", "/vault/notes/new.md", "/vault/tasks.md"}
+	if !reflect.DeepEqual(walked, want) {
+		t.Errorf("got %v, want %v", walked, want)
+	}
+}
+
+func TestClientPrivateKey(t *testing.T) {
+	s := newTestServer(t, map[string]string{"/vault/tasks.md": "- [ ] task"})
+
+	c := s.newClient(t, Config{PrivateKey: s.privateKey})
+	if _, err := c.Read("/vault/tasks.md"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, otherKey := newKey(t)
+	c = s.newClient(t, Config{PrivateKey: otherKey})
+	if _, err := c.Read("/vault/tasks.md"); err == nil {
+		t.Errorf("file is read with the unknown key")
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	s := newTestServer(t, map[string]string{"/vault/tasks.md": "- [ ] task"})
+	c := s.newClient(t, Config{})
+	if _, err := c.Read("/vault/tasks.md"); err != nil {
+		t.Fatal(err)
+	}
+
+	s.dropConnections()
+	if _, err := c.Read("/vault/tasks.md"); err != nil {
+		t.Errorf("connection is not restored: %s", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	s := newTestServer(t, map[string]string{"/vault/tasks.md": "- [ ] task"})
+	c := s.newClient(t, Config{})
+	if _, err := c.Read("/vault/tasks.md"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Read("/vault/tasks.md"); !errors.Is(err, errClosed) {
+		t.Errorf("closed client connects again: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("second close failed: %s", err)
+	}
+}
+
+func TestHostKeyPinning(t *testing.T) {
+	s := newTestServer(t, map[string]string{"/vault/tasks.md": "- [ ] task"})
+
+	config := s.config()
+	config.HostKey = ""
+	if _, err := NewClient(config); !errors.Is(err, ErrHostKeyNotPinned) {
+		t.Errorf("expected not pinned host key, got %v", err)
+	}
+
+	other, _ := newKey(t)
+	config.HostKey = authorizedKey(other.PublicKey())
+	c, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Read("/vault/tasks.md"); err == nil {
+		t.Errorf("file is read from the server with another host key")
+	}
+}
+
+func TestScanHostKey(t *testing.T) {
+	s := newTestServer(t, nil)
+	key, err := ScanHostKey(s.addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != s.hostKey {
+		t.Errorf("got '%s', want '%s'", key, s.hostKey)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = listener.Close()
+	if _, err = ScanHostKey(listener.Addr().String()); !errors.Is(err, vault.ErrUnreachable) {
+		t.Errorf("expected unreachable server, got %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	s := newTestServer(t, map[string]string{"/vault/tasks.md": "- [ ] task"})
+	other, _ := newKey(t)
+
+	tests := []struct {
+		name      string
+		config    func(c *Config)
+		directory string
+		want      error
+	}{
+		{name: "ok", config: func(c *Config) {}, directory: "/vault"},
+		{name: "private key", config: func(c *Config) { c.Password, c.PrivateKey = "", s.privateKey }, directory: "/vault"},
+		{name: "wrong password", config: func(c *Config) { c.Password = "wrong" }, directory: "/vault", want: vault.ErrInvalidCredentials},
+		{name: "not pinned", config: func(c *Config) { c.HostKey = "" }, directory: "/vault", want: vault.ErrUnreachable},
+		{name: "another host key", config: func(c *Config) { c.HostKey = authorizedKey(other.PublicKey()) }, directory: "/vault", want: vault.ErrUnreachable},
+		{name: "missing directory", config: func(c *Config) {}, directory: "/missing", want: vault.ErrDirectoryNotFound},
+		{name: "file", config: func(c *Config) {}, directory: "/vault/tasks.md", want: vault.ErrDirectoryNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := s.config()
+			tt.config(&config)
+			err := Check(config, tt.directory)
+			if tt.want == nil && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"home.lan", "home.lan:22"},
+		{"home.lan:2222", "home.lan:2222"},
+		{"sftp://home.lan/", "home.lan:22"},
+		{"sftp://10.0.0.1:2222", "10.0.0.1:2222"},
+		{"::1", "[::1]:22"},
+	}
+	for _, tt := range tests {
+		if got := address(tt.addr); got != tt.want {
+			t.Errorf("%s: got '%s', want '%s'", tt.addr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package sftp
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/vault"
+	"go-micro.dev/v4/logger"
+)
+
+// WatchInterval is a period of scanning the vault directory
+const WatchInterval = 30 * time.Second
+
+type Watcher struct {
+	c      *Client
+	path   string
+	ch     chan struct{}
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+	digest [sha256.Size]byte
+	l      logger.Logger
+}
+
+func (c *Client) Watch(path string) vault.Watcher {
+	w := &Watcher{
+		c:    c,
+		path: path,
+		ch:   make(chan struct{}),
+		l:    c.l,
+	}
+
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.process()
+	}()
+
+	return w
+}
+
+func (w *Watcher) OnChanged() <-chan struct{} {
+	return w.ch
+}
+
+// Stop stops the polling and closes the connection of the accessor
+func (w *Watcher) Stop() {
+	w.cancel()
+	w.wg.Wait()
+	close(w.ch)
+	_ = w.c.Close()
+}
+
+func (w *Watcher) process() {
+	w.watch()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-time.After(WatchInterval):
+			w.watch()
+		}
+	}
+}
+
+// watch compares the digest of the file paths, sizes and modification times with the previous scan
+func (w *Watcher) watch() {
+	w.l.Log(logger.DebugLevel, "Retrieve changes...")
+
+	h := sha256.New()
+	files := 0
+	err := w.c.Walk(w.path, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if w.ctx.Err() != nil {
+			return w.ctx.Err()
+		}
+		if !info.IsDir() {
+			files++
+			_, _ = fmt.Fprintf(h, "%s\x00%d\x00%d\x00", path, info.Size(), info.ModTime().UnixNano())
+		}
+		return nil
+	})
+	if err != nil {
+		if w.ctx.Err() == nil {
+			w.l.Logf(logger.ErrorLevel, "Retrieve changes failed: %s", err)
+		}
+		return
+	}
+	w.l.Log(logger.DebugLevel, "Retrieve DONE.")
+
+	var digest [sha256.Size]byte
+	copy(digest[:], h.Sum(nil))
+	if digest != w.digest {
+		w.l.Logf(logger.InfoLevel, "Directory changed, files = %d", files)
+		w.digest = digest
+		select {
+		case w.ch <- struct{}{}:
+		case <-w.ctx.Done():
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package vault
+
+import "errors"
+
+var (
+	// ErrInvalidCredentials means the storage rejected the credentials
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrDirectoryNotFound means the vault directory does not exist
+	ErrDirectoryNotFound = errors.New("vault directory not found")
+	// ErrUnreachable means the storage cannot be reached or responds with an unexpected error
+	ErrUnreachable = errors.New("storage is unreachable")
+)
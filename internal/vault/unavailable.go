@@ -0,0 +1,47 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+)
+
+type unavailableAccessor struct {
+	err error
+}
+
+// Unavailable returns the accessor which fails all operations with the error. It is used when the storage cannot be
+// opened, so the vault keeps retrying and reports the error
+func Unavailable(err error) Accessor {
+	return &unavailableAccessor{err: err}
+}
+
+func (a *unavailableAccessor) Read(path string) ([]byte, error) {
+	return nil, a.err
+}
+
+func (a *unavailableAccessor) List(path string) ([]os.FileInfo, error) {
+	return nil, a.err
+}
+
+func (a *unavailableAccessor) Write(path string, content []byte) error {
+	return a.err
+}
+
+func (a *unavailableAccessor) Walk(root string, fn filepath.WalkFunc) error {
+	return a.err
+}
+
+func (a *unavailableAccessor) Watch(path string) Watcher {
+	return &idleWatcher{ch: make(chan struct{})}
+}
+
+type idleWatcher struct {
+	ch chan struct{}
+}
+
+func (w *idleWatcher) OnChanged() <-chan struct{} {
+	return w.ch
+}
+
+func (w *idleWatcher) Stop() {
+}
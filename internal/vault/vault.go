@@ -13,6 +13,12 @@ type Accessor interface {
 	Watch(path string) Watcher
 }
 
+// Committer is implemented by the accessors which keep the history of changes, e.g. a git clone. Commit is called after
+// every modification of the vault, the writes made by the modification are recorded together
+type Committer interface {
+	Commit(message string) error
+}
+
 type Watcher interface {
 	OnChanged() <-chan struct{}
 	Stop()
@@ -0,0 +1,31 @@
+package webdav
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/vault"
+	"github.com/studio-b12/gowebdav"
+)
+
+const checkTimeout = 15 * time.Second
+
+// Check verifies the URL and the credentials by the PROPFIND request of the vault directory
+func Check(config Config, directory string) error {
+	c := newClient(config)
+	c.SetTimeout(checkTimeout)
+
+	fi, err := c.Stat(directory)
+	switch {
+	case err == nil && !fi.IsDir():
+		return fmt.Errorf("%w: %s is not a directory", vault.ErrDirectoryNotFound, directory)
+	case err == nil:
+		return nil
+	case gowebdav.IsErrCode(err, 401) || gowebdav.IsErrCode(err, 403):
+		return vault.ErrInvalidCredentials
+	case gowebdav.IsErrNotFound(err):
+		return fmt.Errorf("%w: %s", vault.ErrDirectoryNotFound, directory)
+	default:
+		return fmt.Errorf("%w: %s", vault.ErrUnreachable, err)
+	}
+}
@@ -0,0 +1,97 @@
+package webdav
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/vault"
+	"github.com/studio-b12/gowebdav"
+	"go-micro.dev/v4/logger"
+)
+
+// Config describes a connection to the WebDAV server
+type Config struct {
+	// URL is a base URL of the WebDAV share, vault paths are relative to it
+	URL string
+	// User and Password are used for basic or digest authentication
+	User     string
+	Password string
+	// Token is used for bearer authentication instead of the user and the password
+	Token string
+	// Headers are added to every request
+	Headers map[string]string
+}
+
+type Client struct {
+	c *gowebdav.Client
+	l logger.Logger
+}
+
+func newClient(config Config) *gowebdav.Client {
+	c := gowebdav.NewClient(config.URL, config.User, config.Password)
+	if config.Token != "" {
+		c.SetHeader("Authorization", "Bearer "+config.Token)
+	}
+	for k, v := range config.Headers {
+		c.SetHeader(k, v)
+	}
+	return c
+}
+
+func NewClient(config Config) vault.Accessor {
+	return NewClientWithLogger(config, logger.Fields(map[string]interface{}{"from": "webdav"}))
+}
+
+// NewClientWithLogger creates the accessor with the own logger, e.g. for the WebDAV based backends
+func NewClientWithLogger(config Config, l logger.Logger) vault.Accessor {
+	return &Client{
+		c: newClient(config),
+		l: l,
+	}
+}
+
+func (c *Client) Read(path string) ([]byte, error) {
+	return c.c.Read(path)
+}
+
+func (c *Client) List(path string) ([]os.FileInfo, error) {
+	return c.c.ReadDir(path)
+}
+
+func (c *Client) Write(path string, content []byte) error {
+	return c.c.Write(path, content, 0644)
+}
+
+func (c *Client) Walk(root string, fn filepath.WalkFunc) error {
+	err := c.walkDir(root, fn)
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func (c *Client) walkDir(root string, fn filepath.WalkFunc) error {
+	files, err := c.List(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+	for _, f := range files {
+		next := filepath.Join(root, f.Name())
+		err = fn(next, f, nil)
+		if err != nil {
+			if f.IsDir() && err == filepath.SkipDir {
+				continue
+			}
+			return err
+		}
+
+		if f.IsDir() {
+			err = c.walkDir(next, fn)
+			if err != nil && err != filepath.SkipDir {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
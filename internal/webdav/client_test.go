@@ -0,0 +1,183 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RacoonMediaServer/rms-notes/internal/vault"
+	"golang.org/x/net/webdav"
+)
+
+const (
+	testUser     = "alice"
+	testPassword = "secret"
+	testToken    = "token"
+)
+
+// newTestServer starts the in-memory WebDAV server which accepts the basic credentials or the bearer token with the
+// custom header
+func newTestServer(t *testing.T, files map[string]string) string {
+	t.Helper()
+	memFS := webdav.NewMemFS()
+	ctx := context.Background()
+	for name, content := range files {
+		dir := ""
+		for _, part := range strings.Split(strings.Trim(filepath.Dir(name), "/"), "/") {
+			dir += "/" + part
+			if err := memFS.Mkdir(ctx, dir, 0755); err != nil && !os.IsExist(err) {
+				t.Fatal(err)
+			}
+		}
+		f, err := memFS.OpenFile(ctx, name, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = f.Write([]byte(content))
+		_ = f.Close()
+	}
+
+	handler := &webdav.Handler{Prefix: "/dav", FileSystem: memFS, LockSystem: webdav.NewMemLS()}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		basic := ok && user == testUser && password == testPassword
+		bearer := r.Header.Get("Authorization") == "Bearer "+testToken && r.Header.Get("X-Vault") == "notes"
+		if !basic && !bearer {
+			w.Header().Set("WWW-Authenticate", `Basic realm="dav"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s.URL + "/dav"
+}
+
+func TestClient(t *testing.T) {
+	url := newTestServer(t, map[string]string{
+		"/vault/tasks.md":         "- [ ] task",
+		"/vault/notes/idea.md":    "idea",
+		"/vault/.obsidian/app.md": "{}",
+	})
+	c := NewClient(Config{URL: url, User: testUser, Password: testPassword})
+
+	content, err := c.Read("/vault/tasks.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "- [ ] task" {
+		t.Errorf("unexpected content: %s", content)
+	}
+
+	if err = c.Write("/vault/notes/new.md", []byte("new note")); err != nil {
+		t.Fatal(err)
+	}
+	if content, err = c.Read("/vault/notes/new.md"); err != nil || string(content) != "new note" {
+		t.Errorf("written note is not read: %s, %v", content, err)
+	}
+
+	files, err := c.List("/vault/notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"idea.md", "new.md"}) {
+		t.Errorf("unexpected files: %v", names)
+	}
+
+	var walked []string
+	err = c.Walk("/vault", func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".obsidian" {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() {
+			walked = append(walked, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(walked)
+	want := []string{"/vault/notes/idea.md", "/vault/notes/new.md", "/vault/tasks.md"}
+	if !reflect.DeepEqual(walked, want) {
+		t.Errorf("got %v, want %v", walked, want)
+	}
+
+	if _, err = c.Read("/vault/missing.md"); err == nil {
+		t.Errorf("missing file is read")
+	}
+}
+
+func TestClientToken(t *testing.T) {
+	url := newTestServer(t, map[string]string{"/vault/tasks.md": "- [ ] task"})
+
+	c := NewClient(Config{URL: url, Token: testToken, Headers: map[string]string{"X-Vault": "notes"}})
+	if _, err := c.Read("/vault/tasks.md"); err != nil {
+		t.Fatal(err)
+	}
+
+	c = NewClient(Config{URL: url, Token: testToken})
+	if _, err := c.Read("/vault/tasks.md"); err == nil {
+		t.Errorf("file is read without the header")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	url := newTestServer(t, map[string]string{"/vault/tasks.md": "- [ ] task"})
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name      string
+		config    Config
+		directory string
+		want      error
+	}{
+		{name: "ok", config: Config{URL: url, User: testUser, Password: testPassword}, directory: "/vault"},
+		{name: "token", config: Config{URL: url, Token: testToken, Headers: map[string]string{"X-Vault": "notes"}}, directory: "/vault"},
+		{name: "wrong password", config: Config{URL: url, User: testUser, Password: "wrong"}, directory: "/vault", want: vault.ErrInvalidCredentials},
+		{name: "missing directory", config: Config{URL: url, User: testUser, Password: testPassword}, directory: "/missing", want: vault.ErrDirectoryNotFound},
+		{name: "file", config: Config{URL: url, User: testUser, Password: testPassword}, directory: "/vault/tasks.md", want: vault.ErrDirectoryNotFound},
+		{name: "unreachable", config: Config{URL: closed.URL, User: testUser, Password: testPassword}, directory: "/vault", want: vault.ErrUnreachable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.config, tt.directory)
+			if tt.want == nil && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	url := newTestServer(t, map[string]string{"/vault/tasks.md": "- [ ] task"})
+	w := NewClient(Config{URL: url, User: testUser, Password: testPassword}).Watch("/vault")
+	defer w.Stop()
+
+	select {
+	case <-w.OnChanged():
+	case <-time.After(time.Second):
+		t.Fatal("initial state is not reported")
+	}
+}
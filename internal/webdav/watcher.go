@@ -1,4 +1,4 @@
-package nextcloud
+package webdav
 
 import (
 	"context"
@@ -0,0 +1,129 @@
+package rms_notes_ext
+
+import rms_notes "github.com/RacoonMediaServer/rms-packages/pkg/service/rms-notes"
+
+// SetTaskStatusRequest moves the task to the status with the symbol, e.g. "/" for in-progress or "-" for cancelled
+type SetTaskStatusRequest struct {
+	User   int32  `json:"user"`
+	Id     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// UserRequest identifies the Telegram user
+type UserRequest struct {
+	User int32 `json:"user"`
+}
+
+// RemindBefore contains lead times of the advance reminders of the user in days, the empty list disables them
+type RemindBefore struct {
+	User int32 `json:"user"`
+	Days []int `json:"days"`
+}
+
+// DigestMode is set if the user receives the daily agenda instead of a message per task
+type DigestMode struct {
+	User    int32 `json:"user"`
+	Enabled bool  `json:"enabled"`
+}
+
+// UserSettings contains personal settings of the user. Nil fields of the request are reset to the global settings, the
+// response contains the effective settings. The server time zone is used if TimeZone is nil
+type UserSettings struct {
+	User             int32   `json:"user"`
+	Directory        *string `json:"directory,omitempty"`
+	NotesDirectory   *string `json:"notesDirectory,omitempty"`
+	TasksFile        *string `json:"tasksFile,omitempty"`
+	NotificationTime *uint32 `json:"notificationTime,omitempty"`
+	// TimeZone is an IANA time zone name, e.g. "Europe/Moscow"
+	TimeZone         *string `json:"timeZone,omitempty"`
+	CompleteSubtasks *bool   `json:"completeSubtasks,omitempty"`
+}
+
+// Vault is a named Obsidian vault. User is the owner of the vault. The vault without own endpoint uses the user
+// credentials, empty paths fall back to the user settings. The connection fields have the same meaning as in
+// LoginRequest, the secrets (the password, the token, the headers and the private key) are never returned
+type Vault struct {
+	User           int32             `json:"user"`
+	Name           string            `json:"name"`
+	Default        bool              `json:"default,omitempty"`
+	Backend        string            `json:"backend,omitempty"`
+	Endpoint       string            `json:"endpoint,omitempty"`
+	Login          string            `json:"login,omitempty"`
+	Password       string            `json:"password,omitempty"`
+	Token          string            `json:"token,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Bucket         string            `json:"bucket,omitempty"`
+	Prefix         string            `json:"prefix,omitempty"`
+	Region         string            `json:"region,omitempty"`
+	Branch         string            `json:"branch,omitempty"`
+	PrivateKey     string            `json:"privateKey,omitempty"`
+	HostKey        string            `json:"hostKey,omitempty"`
+	Directory      string            `json:"directory,omitempty"`
+	NotesDirectory string            `json:"notesDirectory,omitempty"`
+	TasksFile      string            `json:"tasksFile,omitempty"`
+}
+
+// Vaults is a list of the own and shared vaults of the user
+type Vaults struct {
+	Vaults []*Vault `json:"vaults"`
+}
+
+// VaultRequest identifies the vault of the user by name
+type VaultRequest struct {
+	User int32  `json:"user"`
+	Name string `json:"name"`
+}
+
+// ShareVaultRequest shares the vault of the user with the member. The alias is used to assign tasks to the member,
+// e.g. "@alice", it is ignored when the vault is unshared
+type ShareVaultRequest struct {
+	User   int32  `json:"user"`
+	Name   string `json:"name"`
+	Member int32  `json:"member"`
+	Alias  string `json:"alias,omitempty"`
+}
+
+// UpdateCredentialsRequest changes the endpoint, the login or the password of the user. Empty values are not changed
+type UpdateCredentialsRequest struct {
+	User     int32  `json:"user"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Login    string `json:"login,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// StartLoginFlowRequest starts the Nextcloud Login Flow v2 on the server. The login URL is sent to the user by the bot
+type StartLoginFlowRequest struct {
+	User   int32  `json:"user"`
+	Server string `json:"server"`
+}
+
+// LoginRequest contains the credentials of the storage backend chosen by the user. Nextcloud is used if the backend is
+// empty, "webdav" is a plain WebDAV server which accepts the login and the password or the bearer token. For "s3" the
+// login and the password are the access keys, for "git" they authenticate HTTPS remotes. For "sftp" the endpoint is
+// the address of the server, e.g. "host:22"
+type LoginRequest struct {
+	User     int32  `json:"user"`
+	Backend  string `json:"backend,omitempty"`
+	Endpoint string `json:"endpoint"`
+	Login    string `json:"login,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Token is a bearer token of the WebDAV server, it is used instead of the login and the password
+	Token string `json:"token,omitempty"`
+	// Headers are custom HTTP headers of the WebDAV requests
+	Headers map[string]string `json:"headers,omitempty"`
+	// Bucket, Prefix and Region locate the vault in the S3 storage
+	Bucket string `json:"bucket,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	Region string `json:"region,omitempty"`
+	// Branch is a synchronized branch of the git repository, "main" is used if it is empty
+	Branch string `json:"branch,omitempty"`
+	// PrivateKey is a PEM encoded SSH key of the SFTP server, the password is used as its passphrase
+	PrivateKey string `json:"privateKey,omitempty"`
+	// HostKey is a public key of the SFTP server in the authorized_keys format, the key is pinned on login if it is empty
+	HostKey string `json:"hostKey,omitempty"`
+}
+
+// LoginResponse contains the result of the credentials check
+type LoginResponse struct {
+	Code rms_notes.UserLoginResponse_ErrorCode `json:"code"`
+}
@@ -0,0 +1,252 @@
+// Package rms_notes_ext is an extension of the rms-notes service API. It contains the methods which are not a part of
+// the RmsNotes protocol yet. The messages are plain structs, so the calls are encoded in JSON
+package rms_notes_ext
+
+import (
+	"context"
+
+	"go-micro.dev/v4/client"
+	"go-micro.dev/v4/server"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// ContentType is an encoding of the extension calls
+const ContentType = "application/json"
+
+// Client API for RmsNotesExt service
+
+type RmsNotesExtService interface {
+	// Изменить статус задачи
+	SetTaskStatus(ctx context.Context, in *SetTaskStatusRequest, opts ...client.CallOption) (*emptypb.Empty, error)
+	// Получить сроки предварительных напоминаний
+	GetRemindBefore(ctx context.Context, in *UserRequest, opts ...client.CallOption) (*RemindBefore, error)
+	// Установить сроки предварительных напоминаний
+	SetRemindBefore(ctx context.Context, in *RemindBefore, opts ...client.CallOption) (*emptypb.Empty, error)
+	// Получить режим ежедневной сводки
+	GetDigestMode(ctx context.Context, in *UserRequest, opts ...client.CallOption) (*DigestMode, error)
+	// Включить или выключить режим ежедневной сводки
+	SetDigestMode(ctx context.Context, in *DigestMode, opts ...client.CallOption) (*emptypb.Empty, error)
+	// Записать еженедельный обзор задач
+	WriteReview(ctx context.Context, in *UserRequest, opts ...client.CallOption) (*emptypb.Empty, error)
+	// Получить настройки пользователя
+	GetUserSettings(ctx context.Context, in *UserRequest, opts ...client.CallOption) (*UserSettings, error)
+	// Установить настройки пользователя
+	SetUserSettings(ctx context.Context, in *UserSettings, opts ...client.CallOption) (*emptypb.Empty, error)
+	// Получить хранилища пользователя
+	GetVaults(ctx context.Context, in *UserRequest, opts ...client.CallOption) (*Vaults, error)
+	// Добавить хранилище
+	AddVault(ctx context.Context, in *Vault, opts ...client.CallOption) (*emptypb.Empty, error)
+	// Удалить хранилище
+	RemoveVault(ctx context.Context, in *VaultRequest, opts ...client.CallOption) (*emptypb.Empty, error)
+	// Открыть доступ к хранилищу
+	ShareVault(ctx context.Context, in *ShareVaultRequest, opts ...client.CallOption) (*emptypb.Empty, error)
+	// Закрыть доступ к хранилищу
+	UnshareVault(ctx context.Context, in *ShareVaultRequest, opts ...client.CallOption) (*emptypb.Empty, error)
+	// Отвязать заметки от учетной записи пользователя
+	Logout(ctx context.Context, in *UserRequest, opts ...client.CallOption) (*emptypb.Empty, error)
+	// Изменить учетные данные пользователя
+	UpdateCredentials(ctx context.Context, in *UpdateCredentialsRequest, opts ...client.CallOption) (*emptypb.Empty, error)
+	// Начать вход через Nextcloud Login Flow
+	StartLoginFlow(ctx context.Context, in *StartLoginFlowRequest, opts ...client.CallOption) (*emptypb.Empty, error)
+	// Привязать заметки в выбранном хранилище к учетной записи пользователя
+	Login(ctx context.Context, in *LoginRequest, opts ...client.CallOption) (*LoginResponse, error)
+}
+
+type rmsNotesExtService struct {
+	c    client.Client
+	name string
+}
+
+func NewRmsNotesExtService(name string, c client.Client) RmsNotesExtService {
+	return &rmsNotesExtService{
+		c:    c,
+		name: name,
+	}
+}
+
+func (c *rmsNotesExtService) call(ctx context.Context, method string, in, out interface{}, opts ...client.CallOption) error {
+	req := c.c.NewRequest(c.name, "RmsNotesExt."+method, in, client.WithContentType(ContentType))
+	return c.c.Call(ctx, req, out, opts...)
+}
+
+func (c *rmsNotesExtService) SetTaskStatus(ctx context.Context, in *SetTaskStatusRequest, opts ...client.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	if err := c.call(ctx, "SetTaskStatus", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rmsNotesExtService) GetRemindBefore(ctx context.Context, in *UserRequest, opts ...client.CallOption) (*RemindBefore, error) {
+	out := new(RemindBefore)
+	if err := c.call(ctx, "GetRemindBefore", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rmsNotesExtService) SetRemindBefore(ctx context.Context, in *RemindBefore, opts ...client.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	if err := c.call(ctx, "SetRemindBefore", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rmsNotesExtService) GetDigestMode(ctx context.Context, in *UserRequest, opts ...client.CallOption) (*DigestMode, error) {
+	out := new(DigestMode)
+	if err := c.call(ctx, "GetDigestMode", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rmsNotesExtService) SetDigestMode(ctx context.Context, in *DigestMode, opts ...client.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	if err := c.call(ctx, "SetDigestMode", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rmsNotesExtService) WriteReview(ctx context.Context, in *UserRequest, opts ...client.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	if err := c.call(ctx, "WriteReview", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rmsNotesExtService) GetUserSettings(ctx context.Context, in *UserRequest, opts ...client.CallOption) (*UserSettings, error) {
+	out := new(UserSettings)
+	if err := c.call(ctx, "GetUserSettings", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rmsNotesExtService) SetUserSettings(ctx context.Context, in *UserSettings, opts ...client.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	if err := c.call(ctx, "SetUserSettings", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rmsNotesExtService) GetVaults(ctx context.Context, in *UserRequest, opts ...client.CallOption) (*Vaults, error) {
+	out := new(Vaults)
+	if err := c.call(ctx, "GetVaults", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rmsNotesExtService) AddVault(ctx context.Context, in *Vault, opts ...client.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	if err := c.call(ctx, "AddVault", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rmsNotesExtService) RemoveVault(ctx context.Context, in *VaultRequest, opts ...client.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	if err := c.call(ctx, "RemoveVault", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rmsNotesExtService) ShareVault(ctx context.Context, in *ShareVaultRequest, opts ...client.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	if err := c.call(ctx, "ShareVault", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rmsNotesExtService) UnshareVault(ctx context.Context, in *ShareVaultRequest, opts ...client.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	if err := c.call(ctx, "UnshareVault", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rmsNotesExtService) Logout(ctx context.Context, in *UserRequest, opts ...client.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	if err := c.call(ctx, "Logout", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rmsNotesExtService) UpdateCredentials(ctx context.Context, in *UpdateCredentialsRequest, opts ...client.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	if err := c.call(ctx, "UpdateCredentials", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rmsNotesExtService) StartLoginFlow(ctx context.Context, in *StartLoginFlowRequest, opts ...client.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	if err := c.call(ctx, "StartLoginFlow", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rmsNotesExtService) Login(ctx context.Context, in *LoginRequest, opts ...client.CallOption) (*LoginResponse, error) {
+	out := new(LoginResponse)
+	if err := c.call(ctx, "Login", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for RmsNotesExt service
+
+type RmsNotesExtHandler interface {
+	// Изменить статус задачи
+	SetTaskStatus(context.Context, *SetTaskStatusRequest, *emptypb.Empty) error
+	// Получить сроки предварительных напоминаний
+	GetRemindBefore(context.Context, *UserRequest, *RemindBefore) error
+	// Установить сроки предварительных напоминаний
+	SetRemindBefore(context.Context, *RemindBefore, *emptypb.Empty) error
+	// Получить режим ежедневной сводки
+	GetDigestMode(context.Context, *UserRequest, *DigestMode) error
+	// Включить или выключить режим ежедневной сводки
+	SetDigestMode(context.Context, *DigestMode, *emptypb.Empty) error
+	// Записать еженедельный обзор задач
+	WriteReview(context.Context, *UserRequest, *emptypb.Empty) error
+	// Получить настройки пользователя
+	GetUserSettings(context.Context, *UserRequest, *UserSettings) error
+	// Установить настройки пользователя
+	SetUserSettings(context.Context, *UserSettings, *emptypb.Empty) error
+	// Получить хранилища пользователя
+	GetVaults(context.Context, *UserRequest, *Vaults) error
+	// Добавить хранилище
+	AddVault(context.Context, *Vault, *emptypb.Empty) error
+	// Удалить хранилище
+	RemoveVault(context.Context, *VaultRequest, *emptypb.Empty) error
+	// Открыть доступ к хранилищу
+	ShareVault(context.Context, *ShareVaultRequest, *emptypb.Empty) error
+	// Закрыть доступ к хранилищу
+	UnshareVault(context.Context, *ShareVaultRequest, *emptypb.Empty) error
+	// Отвязать заметки от учетной записи пользователя
+	Logout(context.Context, *UserRequest, *emptypb.Empty) error
+	// Изменить учетные данные пользователя
+	UpdateCredentials(context.Context, *UpdateCredentialsRequest, *emptypb.Empty) error
+	// Начать вход через Nextcloud Login Flow
+	StartLoginFlow(context.Context, *StartLoginFlowRequest, *emptypb.Empty) error
+	// Привязать заметки в выбранном хранилище к учетной записи пользователя
+	Login(context.Context, *LoginRequest, *LoginResponse) error
+}
+
+func RegisterRmsNotesExtHandler(s server.Server, hdlr RmsNotesExtHandler, opts ...server.HandlerOption) error {
+	type RmsNotesExt struct {
+		RmsNotesExtHandler
+	}
+	return s.Handle(s.NewHandler(&RmsNotesExt{hdlr}, opts...))
+}
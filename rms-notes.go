@@ -1,11 +1,16 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 
 	"github.com/RacoonMediaServer/rms-notes/internal/config"
 	"github.com/RacoonMediaServer/rms-notes/internal/db"
+	"github.com/RacoonMediaServer/rms-notes/internal/obsidian"
+	"github.com/RacoonMediaServer/rms-notes/internal/secret"
 	notesService "github.com/RacoonMediaServer/rms-notes/internal/service"
+	rms_notes_ext "github.com/RacoonMediaServer/rms-notes/pkg/rms-notes-ext"
 	rms_notes "github.com/RacoonMediaServer/rms-packages/pkg/service/rms-notes"
 	"github.com/RacoonMediaServer/rms-packages/pkg/service/servicemgr"
 	"github.com/urfave/cli/v2"
@@ -58,12 +63,28 @@ func main() {
 
 	_ = servicemgr.NewServiceFactory(service)
 
-	database, err := db.Connect(cfg.Database)
+	if err := obsidian.RegisterStatuses(cfg.Statuses); err != nil {
+		logger.Fatalf("Register task statuses failed: %s", err)
+	}
+
+	database, err := db.Connect(cfg.Database, nil)
 	if err != nil {
 		logger.Fatalf("Connect to database failed: %s", err)
 	}
 
-	ns, err := notesService.New(database, service, cfg.Async)
+	key, err := loadKey(cfg.Security, database)
+	if err != nil {
+		logger.Fatalf("Encryption key is not set: %s", err)
+	}
+	cipher, err := secret.NewCipher(key)
+	if err != nil {
+		logger.Fatalf("Invalid encryption key: %s", err)
+	}
+	if err = database.SetCipher(cipher); err != nil {
+		logger.Fatalf("Encrypt stored passwords failed: %s", err)
+	}
+
+	ns, err := notesService.New(database, service, cfg)
 	if err != nil {
 		logger.Fatalf("Create service failed: %s", err)
 	}
@@ -72,8 +93,33 @@ func main() {
 	if err := rms_notes.RegisterRmsNotesHandler(service.Server(), ns); err != nil {
 		logger.Fatalf("Register service failed: %s", err)
 	}
+	if err := rms_notes_ext.RegisterRmsNotesExtHandler(service.Server(), ns.Ext()); err != nil {
+		logger.Fatalf("Register extension failed: %s", err)
+	}
 
 	if err := service.Run(); err != nil {
 		logger.Fatalf("Run service failed: %s", err)
 	}
 }
+
+// loadKey returns the key set in the configuration or stored in the key file. The key is generated on the first start
+// only, the lost key file is not replaced while the database keeps the secrets encrypted by it
+func loadKey(security config.Security, database *db.Database) (string, error) {
+	if security.EncryptionKey != "" {
+		return security.EncryptionKey, nil
+	}
+	key, err := secret.LoadKey(security.KeyFile)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return key, err
+	}
+
+	encrypted, err := database.HasEncryptedSecrets()
+	if err != nil {
+		return "", fmt.Errorf("check stored secrets failed: %w", err)
+	}
+	if encrypted {
+		return "", fmt.Errorf("key file %s is missing, but the stored secrets are encrypted: restore the file or set the key in the configuration", security.KeyFile)
+	}
+	logger.Infof("Generating encryption key to %s...", security.KeyFile)
+	return secret.CreateKey(security.KeyFile)
+}